@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/brejoc/filtra/persist"
+	"github.com/brejoc/githubv4"
+	log "github.com/sirupsen/logrus"
+)
+
+// issueCache stores the fetched issues of the repositories between runs.
+type issueCache struct {
+	Repositories map[string]*cachedRepository
+}
+
+// cachedRepository stores the issues of a single repository by URL.
+type cachedRepository struct {
+	// FetchedAt is when the last successful fetch of the repository started
+	FetchedAt time.Time
+	// RefreshedAt is when the last successful fetch of all issues started
+	RefreshedAt time.Time
+	Issues      map[string]issue
+	// Partial holds the pages of a failed fetch until it is resumed
+	Partial *partialFetch
+}
+
+// loadIssueCache loads the issue cache from the given file. A missing file
+// results in an empty cache, as does a file that can't be decoded, so that
+// all issues are fetched again.
+func loadIssueCache(path string) (*issueCache, error) {
+	cache := &issueCache{}
+	err := persist.Load(path, cache)
+	if _, isPathError := err.(*os.PathError); isPathError && !os.IsNotExist(err) {
+		return nil, err
+	} else if err != nil && !isPathError {
+		log.Errorf("Not able to decode the issue cache %s, starting with an empty cache: %s", path, err)
+		cache = &issueCache{}
+	}
+	if cache.Repositories == nil {
+		cache.Repositories = map[string]*cachedRepository{}
+	}
+	return cache, nil
+}
+
+// save stores the issue cache in the given file.
+func (cache *issueCache) save(path string) error {
+	return persist.Save(path, cache)
+}
+
+// repository returns the cached issues of the repository with the given full name.
+func (cache *issueCache) repository(fullName string) *cachedRepository {
+	cachedRepo, ok := cache.Repositories[fullName]
+	if !ok {
+		cachedRepo = &cachedRepository{Issues: map[string]issue{}}
+		cache.Repositories[fullName] = cachedRepo
+	}
+	return cachedRepo
+}
+
+// merge adds the fetched issues to the cache, replacing former versions of them.
+func (cachedRepo *cachedRepository) merge(queryPages *QueryPages) {
+	for _, query := range queryPages.Queries {
+		for _, issue := range query.Repository.Issues.Nodes {
+			cachedRepo.Issues[issue.Url.String()] = issue
+		}
+	}
+}
+
+// replace stores the issues of a full fetch as the only cached issues, so that
+// deleted and transferred issues are no longer cached.
+func (cachedRepo *cachedRepository) replace(queryPages *QueryPages) {
+	cachedRepo.Issues = map[string]issue{}
+	cachedRepo.merge(queryPages)
+}
+
+// since returns the time the issues to fetch were updated since. It is nil
+// if all issues need to be fetched, either because none were fetched yet or
+// because the last full refresh is older than the configured interval.
+func (cachedRepo *cachedRepository) since(now time.Time) *githubv4.DateTime {
+	if cachedRepo.FetchedAt.IsZero() || now.Sub(cachedRepo.RefreshedAt) >= config.fullRefreshInterval() {
+		return nil
+	}
+	return githubv4.NewDateTime(githubv4.DateTime{Time: cachedRepo.FetchedAt})
+}
+
+// queryPages returns all cached issues as a single page.
+func (cachedRepo *cachedRepository) queryPages(fullName string) *QueryPages {
+	query := Query{}
+	for _, issue := range cachedRepo.Issues {
+		query.Repository.Issues.Nodes = append(query.Repository.Issues.Nodes, issue)
+	}
+	sort.Slice(query.Repository.Issues.Nodes, func(i, j int) bool {
+		return query.Repository.Issues.Nodes[i].CreatedAt.Before(query.Repository.Issues.Nodes[j].CreatedAt.Time)
+	})
+	query.Repository.Issues.TotalCount = len(query.Repository.Issues.Nodes)
+	return &QueryPages{Repository: fullName, Queries: []Query{query}}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brejoc/filtra/persist"
+	"github.com/brejoc/githubv4"
+	log "github.com/sirupsen/logrus"
+)
+
+func TestIssueCache(t *testing.T) {
+	var results QueryPages
+	if err := persist.Load("./test-data/query_pages.dump", &results); err != nil {
+		log.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "filtra")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cacheFile := filepath.Join(dir, "cache.json")
+
+	// A missing cache file results in an empty cache
+	cache, err := loadIssueCache(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedRepo := cache.repository("brejoc/test")
+	cachedRepo.merge(&results)
+	if err := cache.save(cacheFile); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err = loadIssueCache(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cachedRepo = cache.repository("brejoc/test")
+	if len(cachedRepo.Issues) != 14 {
+		t.Fatalf("Expected 14 cached issues, but got %d", len(cachedRepo.Issues))
+	}
+
+	// Updated issues replace the cached ones
+	updated := results.Queries[0].Repository.Issues.Nodes[0]
+	updated.Title = githubv4.String("updated")
+	updates := QueryPages{Queries: []Query{{}}}
+	updates.Queries[0].Repository.Issues.Nodes = []issue{updated}
+	cachedRepo.merge(&updates)
+
+	queryPages := cachedRepo.queryPages("brejoc/test")
+	nodes := queryPages.Queries[0].Repository.Issues.Nodes
+	if len(nodes) != 14 {
+		t.Fatalf("Expected 14 issues after the update, but got %d", len(nodes))
+	}
+	if nodes[0].Title != "updated" {
+		t.Errorf("Expected the oldest issue to be updated, but got %q", nodes[0].Title)
+	}
+
+	// A full fetch drops the issues that are gone, e.g. deleted or transferred ones
+	cachedRepo.replace(&updates)
+	if len(cachedRepo.Issues) != 1 {
+		t.Errorf("Expected only the fetched issue after a full fetch, but got %d", len(cachedRepo.Issues))
+	}
+	if _, ok := cachedRepo.Issues[updated.Url.String()]; !ok {
+		t.Errorf("Expected %s to be cached after a full fetch", updated.Url.String())
+	}
+
+	// A truncated cache file results in an empty cache
+	if err := ioutil.WriteFile(cacheFile, []byte(`{"Repositories": {"brejoc/test": {"Issu`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cache, err = loadIssueCache(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cache.Repositories) != 0 {
+		t.Errorf("Expected an empty cache, but got %d repositories", len(cache.Repositories))
+	}
+}
+
+func TestCachedRepositorySince(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	now := time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC)
+	cachedRepo := &cachedRepository{}
+	if since := cachedRepo.since(now); since != nil {
+		t.Errorf("Expected all issues to be fetched without former fetch, but got %s", since)
+	}
+
+	// Only the updated issues are fetched until the next full refresh is due
+	cachedRepo.FetchedAt = now.Add(-time.Hour)
+	cachedRepo.RefreshedAt = now.Add(-23 * time.Hour)
+	if since := cachedRepo.since(now); since == nil || !since.Equal(cachedRepo.FetchedAt) {
+		t.Errorf("Expected the issues updated since %s to be fetched, but got %v", cachedRepo.FetchedAt, since)
+	}
+	cachedRepo.RefreshedAt = now.Add(-24 * time.Hour)
+	if since := cachedRepo.since(now); since != nil {
+		t.Errorf("Expected all issues to be fetched after a day, but got %s", since)
+	}
+
+	config.FullRefreshInterval = 3600
+	cachedRepo.RefreshedAt = now.Add(-2 * time.Hour)
+	if since := cachedRepo.since(now); since != nil {
+		t.Errorf("Expected all issues to be fetched after an hour, but got %s", since)
+	}
+}
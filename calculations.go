@@ -1,59 +1,247 @@
 package main
 
 import (
+	"math"
+	"sort"
 	"strings"
 	"time"
-
-	"github.com/brejoc/githubv4"
 )
 
-// Calculates how long an issue was blocked
-func calculateBlockedTime() {
-	//TODO: Implement me
+// Calculates how long an issue was blocked.
+// This is the time the issue spent in any of the blocked columns of the board until it was closed. Multiple stints
+// in blocked columns are summed up.
+func calculateBlockedTime(stints []columnStint, closedAt time.Time, boardName string) time.Duration {
+	return calculateTimeInColumns(stints, closedAt, config.Boards[boardName].isBlockedColumn)
 }
 
-// Calculates how long an issue was worked on
-func calculateWipTime() {
-	//TODO: Implement me
+// Calculates how long an issue was worked on.
+// This is the time the issue spent in any of the active columns of the board until it was closed. Multiple stints
+// in active columns are summed up.
+func calculateWipTime(stints []columnStint, closedAt time.Time, boardName string) time.Duration {
+	return calculateTimeInColumns(stints, closedAt, config.Boards[boardName].isActiveColumn)
 }
 
-// Calculates the cycle time of an issue.
-// Therefore we need to get the date of when the issue was moved to one of the "planned" columns first. The planned
-// columns are defined in the config. The cycle time is the difference between this date and when the issue was closed.
-func calculateCycleTime(timelineItems queryTimelineItems, createdAt githubv4.DateTime,
-	closedAt githubv4.DateTime, boardName string) time.Duration {
-
-	for _, event := range timelineItems.Nodes {
-		if event.Typename == "MovedColumnsInProjectEvent" {
-			if strings.ToLower(string(event.MovedEvent.Project.Name)) == strings.ToLower(boardName) {
-				// previousColumn := strings.ToLower(string(event.MovedEvent.PreviousProjectColumnName))
-				targetColumn := strings.ToLower(string(event.MovedEvent.ProjectColumnName))
-				// Start time is when an issue is moved to a planned (backlog) column
-				if isColumnInColumnSlice(targetColumn, config.Boards[boardName].PlannedColumns) &&
-					event.AddedEvent.CreatedAt.Before(closedAt.Time) {
-					return closedAt.Sub(event.MovedEvent.CreatedAt.Time)
+// Calculates how long an issue was in the columns of a board matched by inColumns until it was closed.
+func calculateTimeInColumns(stints []columnStint, closedAt time.Time, inColumns func(column string) bool) time.Duration {
+	var total time.Duration
+	for column, columnTime := range calculateTimePerColumn(stints, closedAt) {
+		if inColumns(column) {
+			total += columnTime
+		}
+	}
+	return total
+}
+
+// columnStint is the time from which on an issue was in a column of a board.
+type columnStint struct {
+	Column string
+	Since  time.Time
+}
+
+// Calculates the columns an issue was in on a board in the order they happened. The column an issue was added to a
+// board in is taken from the following move or, if there is none, the current column of the issue. Issues without
+// any events on the board are assumed to be in their current column since they were created.
+func calculateColumnStints(events []columnEvent, createdAt time.Time, currentColumn string) []columnStint {
+	if len(events) == 0 {
+		return []columnStint{{Column: currentColumn, Since: createdAt}}
+	}
+
+	stints := []columnStint{}
+	for i, event := range events {
+		column := event.Column
+		if event.Added {
+			column = currentColumn
+			for _, next := range events[i+1:] {
+				if !next.Added {
+					column = next.PreviousColumn
+					break
 				}
 			}
 		}
+		stints = append(stints, columnStint{Column: column, Since: event.CreatedAt})
+	}
+	return stints
+}
+
+// Calculates the columns an issue was in on a board with the times it was removed from the board. After a removal
+// the issue is in no column until it is added again.
+func calculateStintsWithRemovals(stints []columnStint, removals []time.Time) []columnStint {
+	for _, removedAt := range removals {
+		stints = append(stints, columnStint{Since: removedAt})
+	}
+	sort.SliceStable(stints, func(i, j int) bool {
+		return stints[i].Since.Before(stints[j].Since)
+	})
+	return stints
+}
+
+// Calculates how long an issue was in each of the columns of a board until it was closed.
+// The time of a column stint lasts until the next stint or until the issue was closed. Multiple stints in the same
+// column are summed up, stints that started after the issue was closed are left out. Stints without a known column
+// are left out as well.
+func calculateTimePerColumn(stints []columnStint, closedAt time.Time) map[string]time.Duration {
+	times := map[string]time.Duration{}
+	for i, stint := range stints {
+		if !stint.Since.Before(closedAt) {
+			break
+		}
+		if stint.Column == "" {
+			continue
+		}
+		until := closedAt
+		if i+1 < len(stints) && stints[i+1].Since.Before(closedAt) {
+			until = stints[i+1].Since
+		}
+		times[stint.Column] += until.Sub(stint.Since)
+	}
+	return times
+}
+
+// Calculates the cycle time of an issue.
+// Therefore we need to get the date of when the issue was moved to one of the start columns, by default the "planned"
+// columns. The columns are defined in the config. The cycle time is the difference between this date and when the
+// issue was moved to one of the end columns or, if the board has none, when the issue was closed. On Projects (v2)
+// boards the columns are the values of the status field. ok is false if the board skips issues lacking the events.
+func calculateCycleTime(events []columnEvent, createdAt time.Time, closedAt time.Time, boardName string) (time.Duration, bool) {
+	end, ok := calculateCycleEnd(events, closedAt, boardName)
+	if !ok {
+		return 0, false
+	}
+	start, ok := calculateCycleStart(events, createdAt, end, boardName)
+	if !ok {
+		return 0, false
+	}
+	return end.Sub(start), true
+}
+
+// Calculates when the cycle time of an issue started, only taking events before the given time into account.
+// This is when the issue was moved to one of the start columns, by default the first move to one of the planned
+// columns. Issues that were never moved to a start column are handled according to the policy of the board for
+// missing events, ok is false if they are skipped or if the policy is "added" and they have no events on the board.
+func calculateCycleStart(events []columnEvent, createdAt time.Time, before time.Time, boardName string) (time.Time, bool) {
+	b := config.Boards[boardName]
+	if start, ok := calculateColumnEntry(events, b.startColumns(), b.startEntry(), before); ok {
+		return start, true
+	}
+
+	switch b.missingEvents() {
+	case missingEventsSkip:
+		return time.Time{}, false
+	case missingEventsCreated:
+		return createdAt, true
 	}
 
 	// There are cases when issues are added to boards directly in backlog or "in progress" (skipping inbox)
 	// In those cases we consider the time the issue was added to the board as the initial cycle time
-	for _, event := range timelineItems.Nodes {
-		if strings.ToLower(string(event.AddedEvent.Project.Name)) == strings.ToLower(boardName) &&
-			event.AddedEvent.CreatedAt.Before(closedAt.Time) {
-			return closedAt.Sub(event.AddedEvent.CreatedAt.Time)
+	for _, event := range events {
+		if event.CreatedAt.Before(before) {
+			return event.CreatedAt, true
 		}
 	}
 
-	// The issue was not handled correctly. Assume cycle time = lead time in such cases
-	return calculateLeadTime(createdAt, closedAt)
+	// Without any event on the board it is unknown when the issue got there
+	return time.Time{}, false
+}
+
+// Calculates when the work on an issue started, only taking events before the given time into account.
+// This is when the issue was first moved out of the planned columns. Issues that were never moved out of a planned
+// column, e.g. issues added directly to a column in progress, started with their cycle time.
+func calculateWorkStart(events []columnEvent, createdAt time.Time, before time.Time, boardName string) (time.Time, bool) {
+	planned := config.Boards[boardName].PlannedColumns
+	for _, event := range events {
+		if !event.Added && event.CreatedAt.Before(before) &&
+			isColumnInColumnSlice(event.PreviousColumn, planned) && !isColumnInColumnSlice(event.Column, planned) {
+			return event.CreatedAt, true
+		}
+	}
+	return calculateCycleStart(events, createdAt, before, boardName)
+}
+
+// Calculates when the cycle time of a closed issue ended.
+// This is when the issue was moved to one of the end columns of the board, even after it was closed, or when it was
+// closed if the board has no end columns. Issues that were never moved to an end column are handled according to the
+// policy of the board for missing events, ok is false if they are skipped.
+func calculateCycleEnd(events []columnEvent, closedAt time.Time, boardName string) (time.Time, bool) {
+	b := config.Boards[boardName]
+	if len(b.EndColumns) == 0 {
+		return closedAt, true
+	}
+	if end, ok := calculateColumnEntry(events, b.EndColumns, b.endEntry(), time.Time{}); ok {
+		return end, true
+	}
+	if b.missingEvents() == missingEventsSkip {
+		return time.Time{}, false
+	}
+	return closedAt, true
+}
+
+// Calculates when an issue was moved to one of the given columns, only taking events before the given time into
+// account unless it is zero. Depending on the entry this is the first or the last move. ok is false if the issue was
+// never moved to one of the columns.
+func calculateColumnEntry(events []columnEvent, columns []string, entry string, before time.Time) (time.Time, bool) {
+	var entered time.Time
+	found := false
+	for _, event := range events {
+		if event.Added || !isColumnInColumnSlice(event.Column, columns) {
+			continue
+		}
+		if !before.IsZero() && !event.CreatedAt.Before(before) {
+			continue
+		}
+		entered = event.CreatedAt
+		found = true
+		if entry == entryFirst {
+			break
+		}
+	}
+	return entered, found
 }
 
 // Calculates the lead time of an issue.
 // This is the difference between when the issues was created and closed.
-func calculateLeadTime(createdAt githubv4.DateTime, closedAt githubv4.DateTime) time.Duration {
-	return closedAt.Sub(createdAt.Time)
+func calculateLeadTime(createdAt time.Time, closedAt time.Time) time.Duration {
+	return closedAt.Sub(createdAt)
+}
+
+// Calculates how long an issue was open between the given times.
+// This is the sum of the intervals the issue was open in, so the time between being closed and reopened is left out.
+// Intervals of issues that are still open are not taken into account.
+func calculateActiveTime(intervals []activeInterval, since time.Time, until time.Time) time.Duration {
+	var total time.Duration
+	for _, interval := range intervals {
+		if interval.End.IsZero() {
+			continue
+		}
+		start, end := interval.Start, interval.End
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(until) {
+			end = until
+		}
+		if end.After(start) {
+			total += end.Sub(start)
+		}
+	}
+	return total
+}
+
+// Calculates the percentile of the given values with the nearest-rank method. So the percentile is a value out of
+// the given values and for example 85% of the values are less or equal to the 85th percentile.
+func calculatePercentile(values []float64, percentile int) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sorted := append([]float64{}, values...)
+	sort.Float64s(sorted)
+
+	rank := int(math.Ceil(float64(percentile) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	} else if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
 }
 
 // isColumenInColumnSlice checks if a column is in a slice of columns. Cases are ignored.
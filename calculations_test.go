@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math"
+	"reflect"
 	"testing"
 	"time"
 
@@ -19,21 +21,20 @@ func TestCalculateCycleTime(t *testing.T) {
 	node1.AddedEvent = addedEvent{}
 	node1.AddedEvent.Project = project{}
 	node1.AddedEvent.Project.Name = githubv4.String(boardName)
-	node1.AddedEvent.CreatedAt = githubv4.DateTime{currentTime.Add(time.Hour * -24)}
+	node1.AddedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -24)}
 	node1.MovedEvent = movedEvent{}
 	node1.MovedEvent.Project = project{}
 	node1.MovedEvent.Project.Name = githubv4.String(boardName)
 	node1.MovedEvent.PreviousProjectColumnName = "Planned"
 	node1.MovedEvent.ProjectColumnName = "Done"
-	node1.MovedEvent.CreatedAt = githubv4.DateTime{currentTime.Add(time.Hour * -24)}
+	node1.MovedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -24)}
 
 	timelineItems := queryTimelineItems{}
 	timelineItems.Nodes = []node{}
 	timelineItems.Nodes = append(timelineItems.Nodes, *node1)
 
 	want := time.Hour * 24
-	got := calculateCycleTime(timelineItems, node1.MovedEvent.CreatedAt,
-		githubv4.DateTime{currentTime}, boardName)
+	got, _ := calculateCycleTime(boardEvents(timelineItems, boardName), node1.MovedEvent.CreatedAt.Time, currentTime, boardName)
 	if got != want {
 		t.Errorf("Got %s for cycle time, but expected %s", got, want)
 	}
@@ -42,10 +43,363 @@ func TestCalculateCycleTime(t *testing.T) {
 func TestCalculateLeadTime(t *testing.T) {
 	currentTime := time.Now()
 	want := (24 * time.Hour)
-	createdAt := githubv4.DateTime{currentTime}
-	closedAt := githubv4.DateTime{currentTime.Add(want)}
-	got := calculateLeadTime(createdAt, closedAt)
+	got := calculateLeadTime(currentTime, currentTime.Add(want))
 	if got != want {
 		t.Errorf("Expected %s, but got %s for 'leadTime'", want, got)
 	}
 }
+
+func TestCalculateCycleTimeProjectV2(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+	boardName := "test-v2"
+	config.Boards[boardName] = board{
+		ProjectType:    "v2",
+		PlannedColumns: []string{"Todo"},
+		BlockedColumns: []string{"Blocked"},
+	}
+
+	currentTime := time.Now()
+	createdAt := currentTime.Add(time.Hour * -96)
+	closedAt := currentTime
+
+	added := node{Typename: "AddedToProjectV2Event"}
+	added.AddedV2Event.Project.Title = githubv4.String(boardName)
+	added.AddedV2Event.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -72)}
+
+	planned := node{Typename: "ProjectV2ItemStatusChangedEvent"}
+	planned.StatusChangedEvent.Project.Title = githubv4.String(boardName)
+	planned.StatusChangedEvent.PreviousStatus = "Inbox"
+	planned.StatusChangedEvent.Status = "Todo"
+	planned.StatusChangedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -48)}
+
+	// Events of classic boards with the same name are ignored for v2 boards
+	classic := node{Typename: "MovedColumnsInProjectEvent"}
+	classic.AddedEvent.Project.Name = githubv4.String(boardName)
+	classic.AddedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -90)}
+	classic.MovedEvent.Project.Name = githubv4.String(boardName)
+	classic.MovedEvent.ProjectColumnName = "Todo"
+	classic.MovedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -90)}
+
+	timelineItems := queryTimelineItems{Nodes: []node{classic, added, planned}}
+
+	want := time.Hour * 48
+	got, _ := calculateCycleTime(boardEvents(timelineItems, boardName), createdAt, closedAt, boardName)
+	if got != want {
+		t.Errorf("Got %s for cycle time, but expected %s", got, want)
+	}
+
+	// Without a status change the time the item was added to the board is used
+	timelineItems = queryTimelineItems{Nodes: []node{added}}
+	want = time.Hour * 72
+	got, _ = calculateCycleTime(boardEvents(timelineItems, boardName), createdAt, closedAt, boardName)
+	if got != want {
+		t.Errorf("Got %s for cycle time, but expected %s", got, want)
+	}
+}
+
+// movedNode returns a MovedColumnsInProjectEvent on the given board that happened hoursAgo before currentTime.
+func movedNode(boardName, from, to string, currentTime time.Time, hoursAgo int) node {
+	event := node{Typename: "MovedColumnsInProjectEvent"}
+	event.AddedEvent.Project.Name = githubv4.String(boardName)
+	event.AddedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * time.Duration(-hoursAgo))}
+	event.MovedEvent.Project.Name = githubv4.String(boardName)
+	event.MovedEvent.PreviousProjectColumnName = githubv4.String(from)
+	event.MovedEvent.ProjectColumnName = githubv4.String(to)
+	event.MovedEvent.CreatedAt = event.AddedEvent.CreatedAt
+	return event
+}
+
+func TestCalculateBlockedTime(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	currentTime := time.Now()
+	boardName := "test"
+
+	moved := func(from, to string, hoursAgo int) node {
+		return movedNode(boardName, from, to, currentTime, hoursAgo)
+	}
+
+	// Two stints in blocked columns with 10 and 5 hours
+	timelineItems := queryTimelineItems{Nodes: []node{
+		moved("Planned", "In progress", 100),
+		moved("In progress", "Blocked / Postponed", 90),
+		moved("Blocked / Postponed", "In progress", 80),
+		moved("In progress", "Waiting for Request", 20),
+		moved("Waiting for Request", "Done", 15),
+	}}
+
+	want := time.Hour * 15
+	stints := calculateColumnStints(boardEvents(timelineItems, boardName), currentTime.Add(time.Hour*-120), "Done")
+	got := calculateBlockedTime(stints, currentTime, boardName)
+	if got != want {
+		t.Errorf("Got %s for blocked time, but expected %s", got, want)
+	}
+
+	// Issues closed while blocked are blocked until they are closed
+	timelineItems = queryTimelineItems{Nodes: []node{
+		moved("Planned", "Blocked / Postponed", 10),
+	}}
+
+	want = time.Hour * 10
+	stints = calculateColumnStints(boardEvents(timelineItems, boardName), currentTime.Add(time.Hour*-20), "Blocked / Postponed")
+	got = calculateBlockedTime(stints, currentTime, boardName)
+	if got != want {
+		t.Errorf("Got %s for blocked time, but expected %s", got, want)
+	}
+
+	// Issues added directly to a blocked column and closed from there are blocked since they were added
+	added := node{Typename: "AddedToProjectEvent"}
+	added.AddedEvent.Project.Name = githubv4.String(boardName)
+	added.AddedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -30)}
+	timelineItems = queryTimelineItems{Nodes: []node{added}}
+
+	want = time.Hour * 30
+	stints = calculateColumnStints(boardEvents(timelineItems, boardName), currentTime.Add(time.Hour*-40), "Blocked / Postponed")
+	got = calculateBlockedTime(stints, currentTime, boardName)
+	if got != want {
+		t.Errorf("Got %s for blocked time, but expected %s", got, want)
+	}
+
+	// Issues without events on the board are blocked since they were created
+	want = time.Hour * 40
+	stints = calculateColumnStints([]columnEvent{}, currentTime.Add(time.Hour*-40), "Waiting for Request")
+	got = calculateBlockedTime(stints, currentTime, boardName)
+	if got != want {
+		t.Errorf("Got %s for blocked time, but expected %s", got, want)
+	}
+}
+
+func TestCalculateWipTime(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	currentTime := time.Now()
+	boardName := "test"
+
+	added := node{Typename: "AddedToProjectEvent"}
+	added.AddedEvent.Project.Name = githubv4.String(boardName)
+	added.AddedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -120)}
+
+	// Added directly to "In progress", worked on for 20 and 10 hours
+	timelineItems := queryTimelineItems{Nodes: []node{
+		added,
+		movedNode(boardName, "In progress", "Blocked / Postponed", currentTime, 100),
+		movedNode(boardName, "Blocked / Postponed", "Review", currentTime, 80),
+		movedNode(boardName, "Review", "Done", currentTime, 70),
+	}}
+
+	want := time.Hour * 30
+	stints := calculateColumnStints(boardEvents(timelineItems, boardName), currentTime.Add(time.Hour*-150), "Done")
+	got := calculateWipTime(stints, currentTime, boardName)
+	if got != want {
+		t.Errorf("Got %s for work in progress time, but expected %s", got, want)
+	}
+
+	// Added directly to "In progress" and closed from there without any move
+	stints = calculateColumnStints(boardEvents(queryTimelineItems{Nodes: []node{added}}, boardName),
+		currentTime.Add(time.Hour*-150), "In progress")
+	want = time.Hour * 120
+	got = calculateWipTime(stints, currentTime, boardName)
+	if got != want {
+		t.Errorf("Got %s for work in progress time, but expected %s", got, want)
+	}
+
+	// Explicitly configured active columns
+	testBoard := config.Boards[boardName]
+	testBoard.ActiveColumns = []string{"Review"}
+	config.Boards[boardName] = testBoard
+
+	want = time.Hour * 10
+	stints = calculateColumnStints(boardEvents(timelineItems, boardName), currentTime.Add(time.Hour*-150), "Done")
+	got = calculateWipTime(stints, currentTime, boardName)
+	if got != want {
+		t.Errorf("Got %s for work in progress time, but expected %s", got, want)
+	}
+}
+
+func TestCalculatePercentile(t *testing.T) {
+	values := []float64{15, 20, 35, 40, 50}
+	for percentile, want := range map[int]float64{5: 15, 30: 20, 40: 20, 50: 35, 85: 50, 100: 50} {
+		got := calculatePercentile(values, percentile)
+		if got != want {
+			t.Errorf("Got %v for the %dth percentile, but expected %v", got, percentile, want)
+		}
+	}
+
+	if got := calculatePercentile([]float64{}, 50); !math.IsNaN(got) {
+		t.Errorf("Got %v for the percentile of no values, but expected NaN", got)
+	}
+}
+
+func TestCalculateColumnStints(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	currentTime := time.Now()
+	boardName := "test"
+	createdAt := currentTime.Add(time.Hour * -100)
+
+	added := node{Typename: "AddedToProjectEvent"}
+	added.AddedEvent.Project.Name = githubv4.String(boardName)
+	added.AddedEvent.CreatedAt = githubv4.DateTime{Time: currentTime.Add(time.Hour * -50)}
+
+	// The column an issue was added in is taken from the next move
+	timelineItems := queryTimelineItems{Nodes: []node{
+		added,
+		movedNode(boardName, "Planned", "In progress", currentTime, 20),
+	}}
+	want := []columnStint{
+		{Column: "Planned", Since: currentTime.Add(time.Hour * -50)},
+		{Column: "In progress", Since: currentTime.Add(time.Hour * -20)},
+	}
+	got := calculateColumnStints(boardEvents(timelineItems, boardName), createdAt, "In progress")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v as column stints, but expected %v", got, want)
+	}
+
+	// Issues without events are in their current column since they were created
+	want = []columnStint{{Column: "Blocked", Since: createdAt}}
+	got = calculateColumnStints([]columnEvent{}, createdAt, "Blocked")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v as column stints, but expected %v", got, want)
+	}
+}
+
+func TestCalculateActiveTime(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	intervals := []activeInterval{
+		{Start: start, End: start.AddDate(0, 0, 10)},
+		{Start: start.AddDate(0, 0, 100), End: start.AddDate(0, 0, 105)},
+		// Still open
+		{Start: start.AddDate(0, 0, 200)},
+	}
+	end := start.AddDate(0, 0, 105)
+	tests := []struct {
+		since time.Time
+		until time.Time
+		want  time.Duration
+	}{
+		{start, end, 15 * 24 * time.Hour},
+		{start.AddDate(0, 0, 4), end, 11 * 24 * time.Hour},
+		{start.AddDate(0, 0, 50), end, 5 * 24 * time.Hour},
+		{start.AddDate(0, 0, 150), end, 0},
+		{start.AddDate(0, 0, 4), start.AddDate(0, 0, 102), 8 * 24 * time.Hour},
+		{start, start.AddDate(0, 0, 50), 10 * 24 * time.Hour},
+	}
+	for _, test := range tests {
+		if got := calculateActiveTime(intervals, test.since, test.until); got != test.want {
+			t.Errorf("Got %s as active time from %s until %s, but expected %s", got, test.since, test.until, test.want)
+		}
+	}
+}
+
+func TestCalculateCycleTimeBoundaries(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	createdAt := day(1)
+	closedAt := day(20)
+	// Moved back from review to in progress and deployed after being closed
+	events := []columnEvent{
+		{Added: true, CreatedAt: day(2)},
+		{PreviousColumn: "Inbox", Column: "In progress", CreatedAt: day(3)},
+		{PreviousColumn: "In progress", Column: "Review", CreatedAt: day(5)},
+		{PreviousColumn: "Review", Column: "In progress", CreatedAt: day(6)},
+		{PreviousColumn: "In progress", Column: "Review", CreatedAt: day(10)},
+		{PreviousColumn: "Review", Column: "Done", CreatedAt: day(15)},
+		{PreviousColumn: "Done", Column: "Deployed", CreatedAt: day(22)},
+	}
+
+	tests := []struct {
+		board board
+		want  time.Duration
+		ok    bool
+	}{
+		// Without planned columns the cycle time starts when the issue was added
+		{board{}, 18 * 24 * time.Hour, true},
+		{board{StartColumns: []string{"In progress"}}, 17 * 24 * time.Hour, true},
+		{board{StartColumns: []string{"In progress"}, StartEntry: "last"}, 14 * 24 * time.Hour, true},
+		{board{StartColumns: []string{"In progress"}, EndColumns: []string{"Review"}}, 2 * 24 * time.Hour, true},
+		{board{StartColumns: []string{"In progress"}, EndColumns: []string{"Review"}, EndEntry: "last"},
+			7 * 24 * time.Hour, true},
+		{board{StartColumns: []string{"In progress"}, EndColumns: []string{"Deployed"}}, 19 * 24 * time.Hour, true},
+		// Policies for issues never moved to a start or end column
+		{board{StartColumns: []string{"Planned"}, MissingEvents: "created"}, 19 * 24 * time.Hour, true},
+		{board{StartColumns: []string{"Planned"}, MissingEvents: "skip"}, 0, false},
+		{board{StartColumns: []string{"In progress"}, EndColumns: []string{"Released"}}, 17 * 24 * time.Hour, true},
+		{board{StartColumns: []string{"In progress"}, EndColumns: []string{"Released"}, MissingEvents: "skip"}, 0, false},
+	}
+	for _, test := range tests {
+		config.Boards["test"] = test.board
+		got, ok := calculateCycleTime(events, createdAt, closedAt, "test")
+		if got != test.want || ok != test.ok {
+			t.Errorf("Got %s (%v) as cycle time with %+v, but expected %s (%v)", got, ok, test.board, test.want, test.ok)
+		}
+	}
+
+	// Without any events on the board only the "created" policy has a start
+	for policy, want := range map[string]bool{"added": false, "created": true, "skip": false} {
+		config.Boards["test"] = board{MissingEvents: policy}
+		got, ok := calculateCycleTime([]columnEvent{}, createdAt, closedAt, "test")
+		if ok != want || (ok && got != 19*24*time.Hour) {
+			t.Errorf("Got %s (%v) as cycle time without events with %q, but expected ok to be %v", got, ok, policy, want)
+		}
+	}
+}
+
+func TestCalculateTimePerColumn(t *testing.T) {
+	stints := []columnStint{
+		// Stints without a known column are left out
+		{Column: "", Since: day(1)},
+		{Column: "Planned", Since: day(2)},
+		{Column: "In progress", Since: day(3)},
+		{Column: "Review", Since: day(4)},
+		{Column: "In progress", Since: day(6)},
+		{Column: "Done", Since: day(9)},
+		// Moved after the issue was closed
+		{Column: "Deployed", Since: day(12)},
+	}
+	want := map[string]time.Duration{
+		"Planned":     1 * 24 * time.Hour,
+		"In progress": 4 * 24 * time.Hour,
+		"Review":      2 * 24 * time.Hour,
+		"Done":        1 * 24 * time.Hour,
+	}
+	if got := calculateTimePerColumn(stints, day(10)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v as time per column, but expected %v", got, want)
+	}
+}
+
+func TestCalculateWorkStart(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	events := []columnEvent{
+		{Added: true, CreatedAt: day(2)},
+		{PreviousColumn: "Inbox", Column: "Requested", CreatedAt: day(3)},
+		{PreviousColumn: "Requested", Column: "Planned", CreatedAt: day(4)},
+		{PreviousColumn: "Planned", Column: "In progress", CreatedAt: day(6)},
+		{PreviousColumn: "In progress", Column: "Planned", CreatedAt: day(8)},
+		{PreviousColumn: "Planned", Column: "Review", CreatedAt: day(9)},
+	}
+	tests := []struct {
+		events []columnEvent
+		before time.Time
+		want   time.Time
+	}{
+		// The work starts with the first move out of the planned columns
+		{events, day(10), day(6)},
+		// Issues not moved out of the planned columns yet start with their cycle time
+		{events, day(5), day(3)},
+		// Issues never in a planned column start when they were added
+		{events[:1], day(10), day(2)},
+	}
+	for _, test := range tests {
+		got, ok := calculateWorkStart(test.events, day(1), test.before, "test")
+		if !ok || !got.Equal(test.want) {
+			t.Errorf("Got %s (%v) as start of the work, but expected %s", got, ok, test.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import "time"
+
+// CumulativeFlow stores how the number of issues in the columns of a board
+// changed per day. The number of issues in a column at the end of a day is
+// the sum of all changes until then. Days are keyed by their date.
+type CumulativeFlow struct {
+	changes map[string]map[string]int
+}
+
+// newCumulativeFlow returns an empty CumulativeFlow.
+func newCumulativeFlow() CumulativeFlow {
+	return CumulativeFlow{changes: map[string]map[string]int{}}
+}
+
+// add adds the columns an issue was in to the cumulative flow.
+func (flow CumulativeFlow) add(stints []columnStint) {
+	previousColumn := ""
+	for _, stint := range stints {
+		if stint.Column == previousColumn {
+			continue
+		}
+		day := stint.Since.UTC().Format(dayLayout)
+		if flow.changes[day] == nil {
+			flow.changes[day] = map[string]int{}
+		}
+		if previousColumn != "" {
+			flow.changes[day][previousColumn]--
+		}
+		if stint.Column != "" {
+			flow.changes[day][stint.Column]++
+		}
+		previousColumn = stint.Column
+	}
+}
+
+// series returns the number of issues in every column at the end of every day
+// from the first change until the given time by day and column.
+func (flow CumulativeFlow) series(until time.Time) map[string]map[string]interface{} {
+	series := map[string]map[string]interface{}{}
+	if len(flow.changes) == 0 {
+		return series
+	}
+
+	until = until.UTC()
+	first := until
+	for day := range flow.changes {
+		start, err := time.Parse(dayLayout, day)
+		if err == nil && start.Before(first) {
+			first = start
+		}
+	}
+
+	counts := map[string]int{}
+	for day := first; !day.After(until); day = day.AddDate(0, 0, 1) {
+		for column, change := range flow.changes[day.Format(dayLayout)] {
+			counts[column] += change
+		}
+		columns := map[string]interface{}{}
+		for column, count := range counts {
+			columns[column] = count
+		}
+		series[day.Format(dayLayout)] = columns
+	}
+	return series
+}
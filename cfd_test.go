@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCumulativeFlowSeries(t *testing.T) {
+	flow := newCumulativeFlow()
+	flow.add([]columnStint{
+		{Column: "Planned", Since: day(1)},
+		{Column: "In progress", Since: day(2)},
+		{Column: "Done", Since: day(4)},
+	})
+	// Moved twice on the same day
+	flow.add([]columnStint{
+		{Column: "Planned", Since: day(2).Add(-2 * time.Hour)},
+		{Column: "In progress", Since: day(2).Add(-time.Hour)},
+		{Column: "Blocked", Since: day(2).Add(2 * time.Hour)},
+	})
+
+	want := map[string]map[string]interface{}{
+		"2019-06-01": {"Planned": 1},
+		"2019-06-02": {"Planned": 0, "In progress": 1, "Blocked": 1},
+		"2019-06-03": {"Planned": 0, "In progress": 1, "Blocked": 1},
+		"2019-06-04": {"Planned": 0, "In progress": 0, "Blocked": 1, "Done": 1},
+		"2019-06-05": {"Planned": 0, "In progress": 0, "Blocked": 1, "Done": 1},
+	}
+	got := flow.series(day(5).Add(2 * time.Hour))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v as cumulative flow, but expected %v", got, want)
+	}
+}
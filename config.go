@@ -1,30 +1,329 @@
 package main
 
 import (
+	"strings"
+	"time"
+
 	"github.com/BurntSushi/toml"
 	log "github.com/sirupsen/logrus"
 )
 
 // Config stores the values read from the TOML config
 type Config struct {
-	Repository repository
-	Boards     map[string]board
-	Database   database
+	UpdateInterval uint64
+	// Percentiles of the lead and cycle times that are stored for every board
+	Percentiles []int
+	// ReopenedIssues sets when reopened issues count as closed for their lead,
+	// cycle, blocked and WIP times: "last" (default) when they were closed for
+	// the last time, "first" when they were closed for the first time or
+	// "active" for the sum of the intervals they were open in.
+	ReopenedIssues string
+	// CacheFile is where the fetched issues are stored between runs. If it is
+	// set, only the issues updated since the last run are fetched.
+	CacheFile string
+	// FullRefreshInterval is the time in seconds after which all issues are
+	// fetched again despite the cache. Moves on boards do not change when
+	// issues were updated, so they are only noticed then. Defaults to a day.
+	FullRefreshInterval uint64
+	// Repository is the single repository section of older configs. It is
+	// merged into Repositories when the config is loaded.
+	Repository   repository
+	Repositories []repository
+	Boards       map[string]board
+	Database     database
+	Github       github
+	Fetch        fetch
+}
+
+type github struct {
+	// Endpoint is the GraphQL endpoint, e.g. https://github.example.com/api/graphql
+	// for Github Enterprise Server. Defaults to the one of github.com.
+	Endpoint string
+	// CaFile is a PEM bundle of additional CAs to trust, e.g. for self-signed certificates.
+	CaFile string
+	// Proxy is the URL of the proxy for Github. Defaults to the proxy of the environment.
+	Proxy string
+	// AppId, InstallationId and PrivateKeyFile configure the Github App to
+	// authenticate as instead of the token in $GITHUB_TOKEN.
+	AppId          int64
+	InstallationId int64
+	PrivateKeyFile string
+	// RateLimitThreshold is the number of remaining points of the rate limit
+	// below which fetching is paused until the rate limit is reset.
+	RateLimitThreshold int
+}
+
+// fetch configures the requests to the issue trackers of all sources.
+type fetch struct {
+	// MaxRetries is how often a request failing with a transient error is retried.
+	MaxRetries int
+	// RetryBackoff is the delay in seconds before the first retry. It doubles with every retry up to an hour.
+	RetryBackoff uint64
+}
+
+// rateLimitThreshold returns the configured threshold or 100 points by default.
+func (g github) rateLimitThreshold() int {
+	if g.RateLimitThreshold <= 0 {
+		return 100
+	}
+	return g.RateLimitThreshold
+}
+
+// maxRetries returns the configured number of retries or 5 by default.
+func (f fetch) maxRetries() int {
+	if f.MaxRetries <= 0 {
+		return 5
+	}
+	return f.MaxRetries
+}
+
+// retryBackoff returns the configured delay before the first retry or 1 second by default.
+func (f fetch) retryBackoff() time.Duration {
+	if f.RetryBackoff == 0 {
+		return time.Second
+	}
+	return time.Duration(f.RetryBackoff) * time.Second
 }
 
 type repository struct {
-	Owner          string
-	Name           string
+	// Source is the type of issue tracker the repository is on. Defaults to "github".
+	Source string
+	// BaseURL is the address of the issue tracker for sources that can be self-hosted
+	BaseURL string
+	Owner   string
+	Name    string
+	// UpdateInterval is only read from the single [repository] section of
+	// older configs, where it sets the update interval of the config.
 	UpdateInterval uint64
 	BugLabels      []string
 	SupportLabels  []string
+	// ExcludeStateReasons and ExcludeLabels are the state reasons and labels
+	// of closed issues that are left out of the flow metrics and the
+	// throughput, e.g. issues closed as duplicates.
+	ExcludeStateReasons []string
+	ExcludeLabels       []string
+}
+
+// source returns the configured source type of the repository or "github" by default.
+func (r repository) source() string {
+	if r.Source == "" {
+		return "github"
+	}
+	return strings.ToLower(r.Source)
+}
+
+// baseURL returns the configured base URL without trailing slash or the given default.
+func (r repository) baseURL(defaultURL string) string {
+	if r.BaseURL == "" {
+		return defaultURL
+	}
+	return strings.TrimRight(r.BaseURL, "/")
+}
+
+// isExcluded reports whether the item is a closed issue that is left out of
+// the flow metrics by the exclusion rules of the repository. Cases are ignored.
+func (r repository) isExcluded(item workItem) bool {
+	if item.State != stateClosed {
+		return false
+	}
+	if item.StateReason != "" && isColumnInColumnSlice(item.StateReason, r.ExcludeStateReasons) {
+		return true
+	}
+	for _, label := range item.Labels {
+		if isColumnInColumnSlice(label, r.ExcludeLabels) {
+			return true
+		}
+	}
+	return false
+}
+
+// fullName returns the repository name in the "owner/name" notation.
+func (r repository) fullName() string {
+	return r.Owner + "/" + r.Name
+}
+
+// percentiles returns the configured percentiles or the default
+// percentiles 50, 85 and 95.
+func (c Config) percentiles() []int {
+	if len(c.Percentiles) == 0 {
+		return []int{50, 85, 95}
+	}
+	return c.Percentiles
+}
+
+// fullRefreshInterval returns the configured interval of full refreshes of the cache or a day by default.
+func (c Config) fullRefreshInterval() time.Duration {
+	if c.FullRefreshInterval == 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.FullRefreshInterval) * time.Second
+}
+
+// The handling of reopened issues
+const (
+	reopenedLastClose  = "last"
+	reopenedFirstClose = "first"
+	reopenedActiveTime = "active"
+)
+
+// reopenedIssues returns the configured handling of reopened issues or "last" by default.
+func (c Config) reopenedIssues() string {
+	if c.ReopenedIssues == "" {
+		return reopenedLastClose
+	}
+	return strings.ToLower(c.ReopenedIssues)
+}
+
+// repository returns the configured repository with the given full name.
+func (c Config) repository(fullName string) (repository, bool) {
+	for _, repo := range c.Repositories {
+		if repo.fullName() == fullName {
+			return repo, true
+		}
+	}
+	return repository{}, false
 }
 
 type board struct {
+	// ProjectType is either "classic" (default) for classic project boards
+	// or "v2" for Projects (v2) boards.
+	ProjectType string
+	// StatusField is the single-select field holding the column of an item
+	// on a Projects (v2) board. Defaults to "Status". Github only records
+	// the changes of the Status field, so the history of the items and with
+	// it the cycle, blocked and WIP times always come from the Status field.
+	StatusField    string
 	PlannedColumns []string
 	BlockedColumns []string
+	// DoneColumns are the columns of finished issues. Defaults to "Done".
+	DoneColumns []string
+	// ActiveColumns are the columns issues are worked on in. If not set,
+	// all columns that are neither planned, blocked nor done are active.
+	ActiveColumns []string
+	// Windows are the sizes in days of the rolling windows the flow metrics
+	// are additionally calculated for, based on when issues were closed.
+	Windows []int
+	// StartColumns are the columns the cycle time starts with the move to.
+	// Defaults to the planned columns.
+	StartColumns []string
+	// EndColumns are the columns the cycle time ends with the move to, e.g.
+	// "Deployed". If not set, the cycle time ends when issues are closed.
+	EndColumns []string
+	// StartEntry and EndEntry are either "first" (default) for the first move
+	// to one of the start or end columns or "last" for the last move.
+	StartEntry string
+	EndEntry   string
+	// MissingEvents is the policy for issues never moved to a start or end
+	// column: "added" (default) starts the cycle time when they were added to
+	// the board and ends it when they were closed, leaving out issues without
+	// any event on the board, "created" starts it when they were created and
+	// "skip" leaves them out of the flow metrics.
+	MissingEvents string
+	// JiraBoard is the ID of the Jira board whose issues are on the board.
+	// The statuses of the issues are mapped to the columns of the Jira board.
+	JiraBoard int
+	// Jql is the JQL query of the issues on the board for Jira projects
+	// without Jira board. The statuses of the issues are used as columns.
+	Jql string
+}
+
+// isBlockedColumn reports whether the column is one of the blocked columns.
+func (b board) isBlockedColumn(column string) bool {
+	return isColumnInColumnSlice(column, b.BlockedColumns)
+}
+
+// isDoneColumn reports whether the column is one of the done columns.
+func (b board) isDoneColumn(column string) bool {
+	if len(b.DoneColumns) == 0 {
+		return isColumnInColumnSlice(column, []string{"Done"})
+	}
+	return isColumnInColumnSlice(column, b.DoneColumns)
+}
+
+// isEndColumn reports whether the column is one of the end columns of the cycle time.
+func (b board) isEndColumn(column string) bool {
+	return isColumnInColumnSlice(column, b.EndColumns)
+}
+
+// isActiveColumn reports whether issues are worked on in the column. Items on
+// Projects (v2) boards without a status are not worked on yet.
+func (b board) isActiveColumn(column string) bool {
+	if len(b.ActiveColumns) > 0 {
+		return isColumnInColumnSlice(column, b.ActiveColumns)
+	}
+	return column != "" && !(b.isProjectV2() && column == noStatusColumn) &&
+		!isColumnInColumnSlice(column, b.PlannedColumns) &&
+		!b.isBlockedColumn(column) &&
+		!b.isDoneColumn(column)
+}
+
+// The moves to the start or end columns of a board the cycle time starts or ends with
+const (
+	entryFirst = "first"
+	entryLast  = "last"
+)
+
+// The policies for issues never moved to the start or end columns of a board
+const (
+	missingEventsAdded   = "added"
+	missingEventsCreated = "created"
+	missingEventsSkip    = "skip"
+)
+
+// startColumns returns the columns the cycle time starts with or the planned columns by default.
+func (b board) startColumns() []string {
+	if len(b.StartColumns) == 0 {
+		return b.PlannedColumns
+	}
+	return b.StartColumns
+}
+
+// startEntry returns the configured move to the start columns or "first" by default.
+func (b board) startEntry() string {
+	if b.StartEntry == "" {
+		return entryFirst
+	}
+	return strings.ToLower(b.StartEntry)
 }
 
+// endEntry returns the configured move to the end columns or "first" by default.
+func (b board) endEntry() string {
+	if b.EndEntry == "" {
+		return entryFirst
+	}
+	return strings.ToLower(b.EndEntry)
+}
+
+// missingEvents returns the configured policy for issues never moved to the
+// start or end columns or "added" by default.
+func (b board) missingEvents() string {
+	if b.MissingEvents == "" {
+		return missingEventsAdded
+	}
+	return strings.ToLower(b.MissingEvents)
+}
+
+// isJira reports whether the board references a Jira board or JQL query.
+func (b board) isJira() bool {
+	return b.JiraBoard != 0 || b.Jql != ""
+}
+
+// isProjectV2 reports whether the board is a Projects (v2) board.
+func (b board) isProjectV2() bool {
+	return strings.ToLower(b.ProjectType) == "v2"
+}
+
+// statusField returns the name of the field holding the column on a
+// Projects (v2) board.
+func (b board) statusField() string {
+	if b.StatusField == "" {
+		return "Status"
+	}
+	return b.StatusField
+}
+
+// database is the PostgreSQL database the metrics are stored in. It is
+// optional, without it the metrics are only served for Prometheus.
 type database struct {
 	Host     string
 	Port     int
@@ -33,12 +332,57 @@ type database struct {
 	DBname   string
 }
 
+// isConfigured reports whether the config has a [database] section.
+func (d database) isConfigured() bool {
+	return d.Host != ""
+}
+
 // A global config variable
 var config Config
 
 func loadConfig(pathToConfig string) {
+	config = Config{}
+	// The Github authentication might have changed
+	githubTokens = nil
 	if _, err := toml.DecodeFile(pathToConfig, &config); err != nil {
 		log.Fatal(err)
 	}
+
+	// The repositories are all updated together at the top level interval
+	for _, repo := range config.Repositories {
+		if repo.UpdateInterval != 0 {
+			log.Fatalf("Repository %s sets updateInterval, which is only supported at the top level of the config",
+				repo.fullName())
+		}
+	}
+	// Keep supporting configs with a single [repository] section
+	if config.Repository.Owner != "" {
+		config.Repositories = append([]repository{config.Repository}, config.Repositories...)
+		if config.UpdateInterval == 0 {
+			config.UpdateInterval = config.Repository.UpdateInterval
+		}
+	}
+	switch config.reopenedIssues() {
+	case reopenedLastClose, reopenedFirstClose, reopenedActiveTime:
+	default:
+		log.Fatalf("Unknown value %q of reopenedIssues, expected \"last\", \"first\" or \"active\"", config.ReopenedIssues)
+	}
+	for boardName, b := range config.Boards {
+		if b.isProjectV2() && strings.ToLower(b.statusField()) != "status" {
+			log.Warnf("Board %s reads the current columns from the field %s, but the history of the items is only "+
+				"known for the Status field", boardName, b.statusField())
+		}
+		for _, entry := range []string{b.startEntry(), b.endEntry()} {
+			if entry != entryFirst && entry != entryLast {
+				log.Fatalf("Unknown entry %q of board %s, expected \"first\" or \"last\"", entry, boardName)
+			}
+		}
+		switch b.missingEvents() {
+		case missingEventsAdded, missingEventsCreated, missingEventsSkip:
+		default:
+			log.Fatalf("Unknown value %q of missingEvents of board %s, expected \"added\", \"created\" or \"skip\"",
+				b.MissingEvents, boardName)
+		}
+	}
 	log.Debugf("%#v\n", config)
 }
@@ -6,26 +6,34 @@
 -- alter role filtra with superuser login;
 -- create database filtra;
 -- grant all privileges on database filtra to filtra;
+--
+-- The schema can be applied again to upgrade the database of an older version
+-- of Filtra. Missing tables are created and missing columns are added by the
+-- upgrades at the end.
 
 
 -- Types:
 -- * ALL
 -- * BLOCKED
 -- * CLOSED
+-- * EXCLUDED (closed issues left out of the flow metrics)
+-- * INCOMPLETE (boards only, closed issues lacking the events for the cycle time)
 -- * IN_PROGRESS
 -- * OPEN_ISSUE
 -- * OPEN_BUG
 -- * OPEN_L3_BUG
+-- * OVERDUE (boards only)
 -- * PLANNED
 
-CREATE TABLE repo_counter(
+CREATE TABLE IF NOT EXISTS repo_counter(
 	id serial PRIMARY KEY,
 	ts timestamp(4) with time zone NOT NULL,
+	repo varchar(255) NOT NULL,
 	type varchar(255) NOT NULL,
 	value int NOT NULL
 );
 
-CREATE TABLE board_counter(
+CREATE TABLE IF NOT EXISTS board_counter(
 	id serial PRIMARY KEY,
 	ts timestamp(4) with time zone NOT NULL,
 	board varchar(255) NOT NULL,
@@ -34,13 +42,127 @@ CREATE TABLE board_counter(
 );
 
 -- Types:
+-- * BLOCKED_TIME
 -- * CYCLE_TIME
+-- * CYCLE_TIME_P<percentile> (e.g. CYCLE_TIME_P85)
+-- * FLOW_EFFICIENCY
 -- * LEAD_TIME
+-- * LEAD_TIME_P<percentile> (e.g. LEAD_TIME_P85)
+-- * REOPEN_RATE
+-- * WIP_TIME
+
+-- window_days is the size of the rolling window in days the metric was
+-- calculated for, based on when issues were closed. 0 stands for all issues.
+
+CREATE TABLE IF NOT EXISTS board_flow(
+	id serial PRIMARY KEY,
+	ts timestamp(4) with time zone NOT NULL,
+	board varchar(255) NOT NULL,
+	type varchar(255) NOT NULL,
+	value float NOT NULL,
+	window_days int NOT NULL DEFAULT 0
+);
+
+-- Flow metrics of single issues in days.
+-- Types:
+-- * AGE (open issues, time since their cycle time started)
+-- * BLOCKED_TIME (closed issues)
+-- * OVERDUE_AGE (open issues older than the 85th percentile of the cycle time)
+
+CREATE TABLE IF NOT EXISTS issue_flow(
+	id serial PRIMARY KEY,
+	ts timestamp(4) with time zone NOT NULL,
+	board varchar(255) NOT NULL,
+	issue varchar(255) NOT NULL,
+	type varchar(255) NOT NULL,
+	value float NOT NULL
+);
+
+-- Number of closed issues per period, where period_start is the day or the
+-- Monday of the ISO week. The whole history is updated on every run.
+-- Periods:
+-- * DAY
+-- * WEEK
+
+CREATE TABLE IF NOT EXISTS repo_throughput(
+	ts timestamp(4) with time zone NOT NULL,
+	repo varchar(255) NOT NULL,
+	period varchar(255) NOT NULL,
+	period_start date NOT NULL,
+	value int NOT NULL,
+	PRIMARY KEY (repo, period, period_start)
+);
+
+CREATE TABLE IF NOT EXISTS board_throughput(
+	ts timestamp(4) with time zone NOT NULL,
+	board varchar(255) NOT NULL,
+	period varchar(255) NOT NULL,
+	period_start date NOT NULL,
+	value int NOT NULL,
+	PRIMARY KEY (board, period, period_start)
+);
+
+-- Number of issues in every column of a board at the end of each day,
+-- reconstructed from the project events of the issues. The whole history
+-- is updated on every run.
+
+CREATE TABLE IF NOT EXISTS board_cfd(
+	ts timestamp(4) with time zone NOT NULL,
+	board varchar(255) NOT NULL,
+	day date NOT NULL,
+	board_column varchar(255) NOT NULL,
+	value int NOT NULL,
+	PRIMARY KEY (board, day, board_column)
+);
+
+-- Age percentiles in days of the open issues per column of a board.
+-- Types:
+-- * AGE_P<percentile> (e.g. AGE_P85)
+
+CREATE TABLE IF NOT EXISTS board_aging(
+	id serial PRIMARY KEY,
+	ts timestamp(4) with time zone NOT NULL,
+	board varchar(255) NOT NULL,
+	board_column varchar(255) NOT NULL,
+	type varchar(255) NOT NULL,
+	value float NOT NULL
+);
+
+-- Times in days the closed issues spent in a column of a board. Only the
+-- issues that were in the column are taken into account.
+-- Types:
+-- * TIME (average)
+-- * TIME_P<percentile> (e.g. TIME_P85)
 
-CREATE TABLE board_flow(
+CREATE TABLE IF NOT EXISTS board_column_time(
 	id serial PRIMARY KEY,
 	ts timestamp(4) with time zone NOT NULL,
 	board varchar(255) NOT NULL,
+	board_column varchar(255) NOT NULL,
 	type varchar(255) NOT NULL,
 	value float NOT NULL
 );
+
+-- Monte Carlo forecasts of a board created with `filtra forecast`.
+-- Types:
+-- * ITEMS_BY_DATE (target is the date, value the number of items)
+-- * DAYS_FOR_ITEMS (target is the number of items, value the number of days)
+
+CREATE TABLE IF NOT EXISTS board_forecast(
+	id serial PRIMARY KEY,
+	ts timestamp(4) with time zone NOT NULL,
+	board varchar(255) NOT NULL,
+	type varchar(255) NOT NULL,
+	target varchar(255) NOT NULL,
+	confidence int NOT NULL,
+	value int NOT NULL
+);
+
+
+-- Upgrades of the tables of older versions
+
+-- Counters of the single repository before multiple repositories were supported
+ALTER TABLE repo_counter ADD COLUMN IF NOT EXISTS repo varchar(255) NOT NULL DEFAULT '';
+
+-- Flow metrics stored before the rolling windows were calculated are the ones of all issues
+ALTER TABLE board_flow ADD COLUMN IF NOT EXISTS window_days int NOT NULL DEFAULT 0;
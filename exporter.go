@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// Gauges exposed on the /metrics endpoint. They are refreshed after
+// every update run with the values of the latest GithubMetrics.
+var (
+	repoIssuesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gh_repo_issues",
+		Help: "Number of issues inside the repository by type.",
+	}, []string{"repo", "type"})
+
+	boardIssuesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gh_board_issues",
+		Help: "Number of issues on a board by type.",
+	}, []string{"board", "type"})
+
+	boardAgingGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gh_board_aging",
+		Help: "Age percentiles in days of the open issues in a column of a board.",
+	}, []string{"board", "column", "type"})
+
+	boardColumnTimeGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gh_board_column_time",
+		Help: "Average (TIME) and percentiles of the time in days the closed issues spent in a column of a board.",
+	}, []string{"board", "column", "type"})
+
+	boardFlowGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gh_board_flow",
+		Help: "Flow metrics of a board by type and window in days (0 for all issues). Times are in days, the flow efficiency and reopen rate are ratios.",
+	}, []string{"board", "type", "window_days"})
+)
+
+// Gauges about fetching the issues from Github. They are set while fetching.
+var (
+	followUpIssuesGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gh_fetch_follow_up_issues",
+		Help: "Number of issues that needed follow-up queries for their timeline, cards or labels in the last run.",
+	}, []string{"repo"})
+
+	rateLimitRemainingGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "gh_api_rate_limit_remaining",
+		Help: "Remaining points of the Github GraphQL rate limit.",
+	})
+
+	queryCostCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "gh_api_query_cost_total",
+		Help: "Points of the Github GraphQL rate limit used by the queries.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(repoIssuesGauge, boardIssuesGauge, boardAgingGauge, boardColumnTimeGauge, boardFlowGauge)
+	prometheus.MustRegister(followUpIssuesGauge, rateLimitRemainingGauge, queryCostCounter)
+}
+
+// serveMetrics starts the HTTP server for the Prometheus exposition.
+// It is meant to be started in its own goroutine.
+func serveMetrics(address string) {
+	http.Handle("/metrics", promhttp.Handler())
+	log.Infof("Serving metrics on %s/metrics", address)
+	if err := http.ListenAndServe(address, nil); err != nil {
+		log.Fatalf("Unable to serve metrics: %s", err)
+	}
+}
+
+func (metrics GithubMetrics) writeToExporter() {
+	repoIssuesGauge.Reset()
+	for repoName, repoMetrics := range metrics.Repo {
+		repoIssuesGauge.WithLabelValues(repoName, "OPEN").Set(float64(repoMetrics.openIssueCounter))
+		repoIssuesGauge.WithLabelValues(repoName, "CLOSED").Set(float64(repoMetrics.closedIssueCounter))
+		repoIssuesGauge.WithLabelValues(repoName, "OPEN_BUG").Set(float64(repoMetrics.openBugsCounter))
+		repoIssuesGauge.WithLabelValues(repoName, "OPEN_L3_BUG").Set(float64(repoMetrics.openL3Counter))
+		repoIssuesGauge.WithLabelValues(repoName, "EXCLUDED").Set(float64(repoMetrics.excludedIssueCounter))
+	}
+
+	boardIssuesGauge.Reset()
+	boardAgingGauge.Reset()
+	boardColumnTimeGauge.Reset()
+	boardFlowGauge.Reset()
+	for boardName, boardMetrics := range metrics.Board {
+		boardIssuesGauge.WithLabelValues(boardName, "OPEN").Set(float64(boardMetrics.openIssueCounter))
+		boardIssuesGauge.WithLabelValues(boardName, "CLOSED").Set(float64(boardMetrics.closedIssueCounter))
+		boardIssuesGauge.WithLabelValues(boardName, "BLOCKED").Set(float64(boardMetrics.blockedIssueCounter))
+		boardIssuesGauge.WithLabelValues(boardName, "PLANNED").Set(float64(boardMetrics.plannedIssueCounter))
+		boardIssuesGauge.WithLabelValues(boardName, "OPEN_BUG").Set(float64(boardMetrics.openBugsCounter))
+		boardIssuesGauge.WithLabelValues(boardName, "OPEN_L3_BUG").Set(float64(boardMetrics.openL3Counter))
+		boardIssuesGauge.WithLabelValues(boardName, "OVERDUE").Set(float64(boardMetrics.overdueIssueCounter))
+		boardIssuesGauge.WithLabelValues(boardName, "EXCLUDED").Set(float64(boardMetrics.excludedIssueCounter))
+		boardIssuesGauge.WithLabelValues(boardName, "INCOMPLETE").Set(float64(boardMetrics.incompleteIssueCounter))
+
+		for column, percentiles := range boardMetrics.columnAgePercentiles {
+			for percentile, age := range percentiles {
+				boardAgingGauge.WithLabelValues(boardName, column, fmt.Sprintf("AGE_P%d", percentile)).Set(age)
+			}
+		}
+
+		for column, percentiles := range boardMetrics.columnTimePercentiles {
+			boardColumnTimeGauge.WithLabelValues(boardName, column, "TIME").Set(boardMetrics.averageColumnTime[column])
+			for percentile, columnTime := range percentiles {
+				boardColumnTimeGauge.WithLabelValues(boardName, column, fmt.Sprintf("TIME_P%d", percentile)).Set(columnTime)
+			}
+		}
+
+		setFlowGauge(boardName, 0, boardMetrics.FlowMetrics)
+		for days, windowMetrics := range boardMetrics.windows {
+			setFlowGauge(boardName, days, windowMetrics)
+		}
+	}
+}
+
+// setFlowGauge sets the flow metrics of a board for the window with the given
+// size in days. A size of 0 stands for all closed issues.
+func setFlowGauge(boardName string, days int, flow FlowMetrics) {
+	for flowType, value := range flow.toMap() {
+		boardFlowGauge.WithLabelValues(boardName, flowType, strconv.Itoa(days)).Set(value.(float64))
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brejoc/filtra/persist"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestWriteToExporter(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	var results QueryPages
+	if err := persist.Load("./test-data/query_pages.dump", &results); err != nil {
+		t.Fatal(err)
+	}
+	now = func() time.Time { return time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	metrics := NewMetrics(results.workItems())
+	metrics.writeToExporter()
+
+	// Scrapes the gauges like Prometheus does
+	recorder := httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the metrics to be served, but got %d", recorder.Code)
+	}
+	exposition := recorder.Body.String()
+
+	flow := metrics.Board["test"].FlowMetrics
+	for _, sample := range []string{
+		`gh_repo_issues{repo="brejoc/test",type="OPEN"} 9`,
+		`gh_repo_issues{repo="brejoc/test",type="CLOSED"} 5`,
+		`gh_repo_issues{repo="brejoc/test",type="OPEN_L3_BUG"} 2`,
+		`gh_board_issues{board="test",type="BLOCKED"} 3`,
+		fmt.Sprintf(`gh_board_flow{board="test",type="LEAD_TIME",window_days="0"} %s`,
+			strconv.FormatFloat(flow.averageLeadTime, 'g', -1, 64)),
+		fmt.Sprintf(`gh_board_flow{board="test",type="CYCLE_TIME_P85",window_days="0"} %s`,
+			strconv.FormatFloat(flow.cycleTimePercentiles[85], 'g', -1, 64)),
+	} {
+		if !strings.Contains(exposition, sample+"\n") {
+			t.Errorf("Expected the sample %s to be exposed", sample)
+		}
+	}
+
+	// Repositories and boards of former runs are not exposed anymore
+	GithubMetrics{}.writeToExporter()
+	recorder = httptest.NewRecorder()
+	promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(recorder.Body.String(), `repo="brejoc/test"`) {
+		t.Error("Expected the gauges to be reset")
+	}
+}
@@ -22,62 +22,95 @@ const (
 var db *sql.DB
 
 func updateLoop() {
-	log.Infof("Updating metrics from Github: %s", time.Now())
-	issues, err := FetchAllIssues()
+	log.Infof("Updating metrics: %s", time.Now())
+	issues, err := FetchAllWorkItems()
 	if err != nil {
-		log.Error("Not able to fetch issues from Github: ", err)
+		log.Error("Not able to fetch issues: ", err)
 	} else {
-		metrics := NewMetrics(issues)
-		metrics.writeToDB(db)
+		metrics := NewMetrics(issues...)
+		if db != nil {
+			metrics.writeToDB(db)
+		}
+		metrics.writeToExporter()
 		log.Infof("Update finished: %s", time.Now())
-		log.Debugf("Update interval: %d", config.Repository.UpdateInterval)
+		log.Debugf("Update interval: %d", config.UpdateInterval)
 	}
 }
 
-func run(args []string, stdout io.Writer) error {
-	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
-	var (
-		debugFlag      = flags.Bool("debug", false, "Sets log level to debug.")
-		configFileFlag = flags.String("config", "./config.toml", "Path to config file")
-	)
-	if err := flags.Parse(args[1:]); err != nil {
-		return err
-	}
-
+// setup loads the config file and connects to PostgreSQL if a database is configured.
+func setup(configFile string, debug bool) {
 	// Setting logger to debug level when debug flag was set.
-	if *debugFlag == true {
+	if debug == true {
 		log.SetLevel(log.DebugLevel)
-		log.Debugf("Config: %+v", config)
 	}
 
 	// globally load toml config
-	if fileExists(*configFileFlag) {
-		loadConfig(*configFileFlag)
+	if fileExists(configFile) {
+		loadConfig(configFile)
+		log.Debugf("Config: %+v", config)
 	} else {
 		log.Fatal("Please provide a config file with `-config <yourconfig>` or just create `config.toml` in this directory")
 	}
 
-	// Make sure update interval has a default value
-	updateInterval := uint64(config.Repository.UpdateInterval)
-	if updateInterval <= 0 {
-		updateInterval = 1800 // 30 mins
+	// Fail early without authentication for Github
+	for _, repo := range config.Repositories {
+		if repo.source() == "github" {
+			if _, err := githubTokenSource(); err != nil {
+				log.Fatal(err)
+			}
+			break
+		}
 	}
 
-	// Initialize connection to sqlite database
-	var err error
+	// Without a database the metrics are only served for Prometheus
+	if !config.Database.isConfigured() {
+		log.Info("No database configured, the metrics are not stored in PostgreSQL")
+		return
+	}
+
+	// Initialize connection to PostgreSQL database
 	var psqlConfig = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		config.Database.Host, config.Database.Port, config.Database.User, config.Database.Password, config.Database.DBname)
 
 	// Connect to PostgreSQL
 	db, _ = sql.Open("postgres", psqlConfig)
-	defer db.Close()
 	// Test if our connection actually works
-	err = db.Ping()
-	if err != nil {
+	if err := db.Ping(); err != nil {
 		log.Fatalf("Unable to connect to PostgreSQL: %s", err)
 	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	// Subcommands
+	if len(args) > 1 && args[1] == "forecast" {
+		return runForecast(args[1:], stdout)
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	var (
+		debugFlag      = flags.Bool("debug", false, "Sets log level to debug.")
+		configFileFlag = flags.String("config", "./config.toml", "Path to config file")
+		listenFlag     = flags.String("listen", ":8080", "Address to serve the Prometheus metrics on")
+	)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	// Expose the metrics for Prometheus, also while the rest is set up
+	go serveMetrics(*listenFlag)
+
+	setup(*configFileFlag, *debugFlag)
+	if db != nil {
+		defer db.Close()
+	}
+
+	// Make sure update interval has a default value
+	updateInterval := uint64(config.UpdateInterval)
+	if updateInterval <= 0 {
+		updateInterval = 1800 // 30 mins
+	}
 
-	// Poll Github and update DB on a regular interval
+	// Poll the issue sources and update DB on a regular interval
 	for {
 		updateLoop()
 		time.Sleep(time.Duration(updateInterval) * time.Second)
@@ -0,0 +1,8 @@
+package main
+
+import "time"
+
+// day returns the given day of June 2019 at 10:00 UTC, the month the test fixtures are set in.
+func day(d int) time.Time {
+	return time.Date(2019, 6, d, 10, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// forecastConfidences are the confidence levels of a forecast in percent.
+var forecastConfidences = []int{50, 85, 95}
+
+// Forecast stores the results of the Monte Carlo simulations for a board.
+type Forecast struct {
+	Board string
+	// Date and Items are the targets the forecast was made for. Zero values
+	// mean that the forecast was not requested.
+	Date  time.Time
+	Items int
+	// ItemsByDate holds how many items are at least done by Date by confidence
+	ItemsByDate map[int]int
+	// DaysForItems holds in how many days Items are done at the latest by confidence
+	DaysForItems map[int]int
+}
+
+// lastDays returns the throughput of the given number of days before until,
+// oldest first.
+func (throughput Throughput) lastDays(until time.Time, days int) []int {
+	series := throughput.daySeries(until)
+	samples := []int{}
+	for day := until.UTC().AddDate(0, 0, -days); day.Before(until); day = day.AddDate(0, 0, 1) {
+		if count, ok := series[day.Format(dayLayout)]; ok {
+			samples = append(samples, count.(int))
+		} else {
+			samples = append(samples, 0)
+		}
+	}
+	return samples
+}
+
+// simulateItems simulates how many items are done within the given number of
+// days by sampling the daily throughput. It returns the result of every simulation.
+func simulateItems(dailyThroughput []int, days int, simulations int, rng *rand.Rand) []int {
+	results := make([]int, simulations)
+	for i := range results {
+		for day := 0; day < days; day++ {
+			results[i] += dailyThroughput[rng.Intn(len(dailyThroughput))]
+		}
+	}
+	return results
+}
+
+// simulateDays simulates how many days it takes to finish the given number of
+// items by sampling the daily throughput. It returns the result of every simulation.
+func simulateDays(dailyThroughput []int, items int, simulations int, rng *rand.Rand) []int {
+	results := make([]int, simulations)
+	for i := range results {
+		for done := 0; done < items; results[i]++ {
+			done += dailyThroughput[rng.Intn(len(dailyThroughput))]
+		}
+	}
+	return results
+}
+
+// newForecast runs the Monte Carlo simulations for how many items are done by
+// the given date and in how many days the given number of items are done.
+func newForecast(boardName string, dailyThroughput []int, date time.Time, items int, simulations int,
+	rng *rand.Rand) (Forecast, error) {
+
+	forecast := Forecast{
+		Board:        boardName,
+		Date:         date,
+		Items:        items,
+		ItemsByDate:  map[int]int{},
+		DaysForItems: map[int]int{},
+	}
+
+	total := 0
+	for _, count := range dailyThroughput {
+		total += count
+	}
+	if total == 0 {
+		return forecast, errors.New("no issues were closed in the sampled days")
+	}
+
+	if !date.IsZero() {
+		days := int(date.Sub(now()).Hours()/24) + 1
+		results := simulateItems(dailyThroughput, days, simulations, rng)
+		sort.Ints(results)
+		for _, confidence := range forecastConfidences {
+			// confidence% of the simulations finished at least this many items
+			forecast.ItemsByDate[confidence] = results[(100-confidence)*(simulations-1)/100]
+		}
+	}
+
+	if items > 0 {
+		results := simulateDays(dailyThroughput, items, simulations, rng)
+		sort.Ints(results)
+		for _, confidence := range forecastConfidences {
+			// confidence% of the simulations finished within this many days
+			forecast.DaysForItems[confidence] = results[confidence*(simulations-1)/100]
+		}
+	}
+	return forecast, nil
+}
+
+// print writes the forecast in a human readable form.
+func (forecast Forecast) print(w io.Writer) {
+	fmt.Fprintf(w, "Forecast for board %s\n", forecast.Board)
+	if !forecast.Date.IsZero() {
+		fmt.Fprintf(w, "\nItems done by %s:\n", forecast.Date.Format(dayLayout))
+		for _, confidence := range forecastConfidences {
+			fmt.Fprintf(w, "  %d%%: %d or more\n", confidence, forecast.ItemsByDate[confidence])
+		}
+	}
+	if forecast.Items > 0 {
+		fmt.Fprintf(w, "\n%d items done by:\n", forecast.Items)
+		for _, confidence := range forecastConfidences {
+			days := forecast.DaysForItems[confidence]
+			fmt.Fprintf(w, "  %d%%: %s (%d days)\n", confidence, now().AddDate(0, 0, days).Format(dayLayout), days)
+		}
+	}
+}
+
+// writeToDB stores the forecast.
+func (forecast Forecast) writeToDB(db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("insert into board_forecast(ts, board, type, target, confidence, value) " +
+		"values ($1, $2, $3, $4, $5, $6)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	timeNow := time.Now()
+	for confidence, items := range forecast.ItemsByDate {
+		if _, err := stmt.Exec(timeNow, forecast.Board, "ITEMS_BY_DATE", forecast.Date.Format(dayLayout),
+			confidence, items); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for confidence, days := range forecast.DaysForItems {
+		if _, err := stmt.Exec(timeNow, forecast.Board, "DAYS_FOR_ITEMS", fmt.Sprint(forecast.Items),
+			confidence, days); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// parseForecastDate parses the date the forecast is made for. It may be today
+// or a later day, the date and today both count from midnight UTC.
+func parseForecastDate(value string, now time.Time) (time.Time, error) {
+	date, err := time.Parse(dayLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: %s", value, err)
+	}
+	if date.Before(now.UTC().Truncate(24 * time.Hour)) {
+		return time.Time{}, fmt.Errorf("date %s is in the past", value)
+	}
+	return date, nil
+}
+
+// runForecast runs the forecast subcommand.
+func runForecast(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet(args[0], flag.ExitOnError)
+	var (
+		debugFlag       = flags.Bool("debug", false, "Sets log level to debug.")
+		configFileFlag  = flags.String("config", "./config.toml", "Path to config file")
+		boardFlag       = flags.String("board", "", "Board to forecast")
+		itemsFlag       = flags.Int("items", 0, "Forecast by when this number of items is done")
+		dateFlag        = flags.String("date", "", "Forecast how many items are done by this date (YYYY-MM-DD)")
+		historyFlag     = flags.Int("history", 90, "Number of past days the throughput is sampled from")
+		simulationsFlag = flags.Int("simulations", 10000, "Number of simulations")
+	)
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	var date time.Time
+	if *dateFlag != "" {
+		var err error
+		if date, err = parseForecastDate(*dateFlag, now()); err != nil {
+			return err
+		}
+	}
+	if date.IsZero() && *itemsFlag <= 0 {
+		return errors.New("please provide the number of items with `-items` and/or a date with `-date`")
+	}
+	if *historyFlag <= 0 || *simulationsFlag <= 0 {
+		return errors.New("history and simulations need to be greater than 0")
+	}
+
+	setup(*configFileFlag, *debugFlag)
+	if db != nil {
+		defer db.Close()
+	}
+
+	if _, ok := config.Boards[*boardFlag]; !ok {
+		return fmt.Errorf("board %q is not configured", *boardFlag)
+	}
+
+	issues, err := FetchAllWorkItems()
+	if err != nil {
+		return fmt.Errorf("not able to fetch issues: %s", err)
+	}
+	metrics := NewMetrics(issues...)
+	dailyThroughput := metrics.Board[*boardFlag].throughput.lastDays(now(), *historyFlag)
+	log.Debugf("Daily throughput of the last %d days: %v", *historyFlag, dailyThroughput)
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	forecast, err := newForecast(*boardFlag, dailyThroughput, date, *itemsFlag, *simulationsFlag, rng)
+	if err != nil {
+		return err
+	}
+	forecast.print(stdout)
+	if db == nil {
+		return nil
+	}
+	return forecast.writeToDB(db)
+}
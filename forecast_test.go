@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewForecast(t *testing.T) {
+	now = func() time.Time { return time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	rng := rand.New(rand.NewSource(1))
+
+	// With a constant throughput every simulation has the same result
+	date := time.Date(2019, 9, 10, 0, 0, 0, 0, time.UTC)
+	forecast, err := newForecast("test", []int{2, 2, 2}, date, 25, 100, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]int{50: 18, 85: 18, 95: 18}; !reflect.DeepEqual(forecast.ItemsByDate, want) {
+		t.Errorf("Got %v items by date, but expected %v", forecast.ItemsByDate, want)
+	}
+	if want := map[int]int{50: 13, 85: 13, 95: 13}; !reflect.DeepEqual(forecast.DaysForItems, want) {
+		t.Errorf("Got %v days for items, but expected %v", forecast.DaysForItems, want)
+	}
+
+	// Higher confidence means less items and more days
+	forecast, err = newForecast("test", []int{0, 1, 0, 3, 5, 0, 2}, date, 25, 1000, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forecast.ItemsByDate[95] > forecast.ItemsByDate[85] || forecast.ItemsByDate[85] > forecast.ItemsByDate[50] {
+		t.Errorf("Expected less items for higher confidences, but got %v", forecast.ItemsByDate)
+	}
+	if forecast.DaysForItems[95] < forecast.DaysForItems[85] || forecast.DaysForItems[85] < forecast.DaysForItems[50] {
+		t.Errorf("Expected more days for higher confidences, but got %v", forecast.DaysForItems)
+	}
+
+	var out bytes.Buffer
+	forecast.print(&out)
+	if !strings.Contains(out.String(), "Items done by 2019-09-10") {
+		t.Errorf("Forecast output is missing the date: %s", out.String())
+	}
+
+	if _, err := newForecast("test", []int{0, 0}, date, 25, 100, rng); err == nil {
+		t.Error("Expected an error for a throughput without closed issues")
+	}
+}
+
+func TestParseForecastDate(t *testing.T) {
+	now := time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"2019-09-10", time.Date(2019, 9, 10, 0, 0, 0, 0, time.UTC), false},
+		// Today is not in the past, even though its midnight is
+		{"2019-09-01", time.Date(2019, 9, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2019-08-31", time.Time{}, true},
+		{"01.09.2019", time.Time{}, true},
+	}
+	for _, test := range tests {
+		got, err := parseForecastDate(test.value, now)
+		if (err != nil) != test.wantErr {
+			t.Errorf("Got error %v for %s, but expected an error: %t", err, test.value, test.wantErr)
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("Got %s for %s, but expected %s", got, test.value, test.want)
+		}
+	}
+}
+
+func TestThroughputLastDays(t *testing.T) {
+	throughput := newThroughput()
+	throughput.add(time.Date(2019, 8, 29, 10, 0, 0, 0, time.UTC))
+	throughput.add(time.Date(2019, 8, 31, 10, 0, 0, 0, time.UTC))
+	throughput.add(time.Date(2019, 8, 31, 11, 0, 0, 0, time.UTC))
+	// Today is not sampled
+	throughput.add(time.Date(2019, 9, 1, 10, 0, 0, 0, time.UTC))
+
+	want := []int{0, 0, 1, 0, 2}
+	got := throughput.lastDays(time.Date(2019, 9, 1, 12, 0, 0, 0, time.UTC), 5)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v as throughput of the last days, but expected %v", got, want)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// giteaPageSize is the number of items requested per page from Gitea. Instances
+// might return fewer items per page if their maximum is lower.
+const giteaPageSize = 50
+
+// giteaSource fetches the issues of repositories on Gitea or Forgejo. Their
+// released APIs have no endpoints for projects, so the issues are on no board.
+type giteaSource struct{}
+
+type giteaIssue struct {
+	Number    int       `json:"number"`
+	Title     string    `json:"title"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+	ClosedAt  time.Time `json:"closed_at"`
+	HTMLURL   string    `json:"html_url"`
+	Labels    []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+// giteaRepository requests the API of a single Gitea repository.
+type giteaRepository struct {
+	repoURL string
+	header  http.Header
+}
+
+// getAll requests all pages of the given path and calls appendPage after
+// every page was decoded into v. The pages end with the total count of the
+// X-Total-Count header, with the last page of the Link header or, if the
+// instance sends neither, with the first empty page.
+func (gitea giteaRepository) getAll(address string, v interface{}, appendPage func() int) error {
+	separator := "?"
+	if strings.Contains(address, "?") {
+		separator = "&"
+	}
+	count := 0
+	for page := 1; ; page++ {
+		header, err := getJSON(fmt.Sprintf("%s%slimit=%d&page=%d", address, separator, giteaPageSize, page),
+			gitea.header, v)
+		if err != nil {
+			return err
+		}
+		pageCount := appendPage()
+		count += pageCount
+		if pageCount == 0 {
+			return nil
+		}
+		if total, err := strconv.Atoi(header.Get("X-Total-Count")); err == nil {
+			if count >= total {
+				return nil
+			}
+		} else if header.Get("Link") != "" && !strings.Contains(header.Get("Link"), `rel="next"`) {
+			return nil
+		}
+	}
+}
+
+func (giteaSource) fetch(repos []repository) ([]*WorkItems, error) {
+	results := []*WorkItems{}
+	for _, repo := range repos {
+		workItems, err := fetchGiteaRepository(repo)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		results = append(results, workItems)
+	}
+	return results, nil
+}
+
+// fetchGiteaRepository fetches the issues of a Gitea repository.
+func fetchGiteaRepository(repo repository) (*WorkItems, error) {
+	apiURL := repo.baseURL("https://codeberg.org") + "/api/v1"
+	gitea := giteaRepository{
+		repoURL: fmt.Sprintf("%s/repos/%s/%s", apiURL, repo.Owner, repo.Name),
+		header:  http.Header{"Authorization": []string{"token " + os.Getenv("GITEA_TOKEN")}},
+	}
+	if len(config.Boards) > 0 {
+		log.Warnf("Boards are not supported for Gitea and Forgejo, the issues of %s are on no board", repo.fullName())
+	}
+
+	issues := []giteaIssue{}
+	issuePage := []giteaIssue{}
+	if err := gitea.getAll(gitea.repoURL+"/issues?state=all&type=issues", &issuePage, func() int {
+		issues = append(issues, issuePage...)
+		return len(issuePage)
+	}); err != nil {
+		return nil, err
+	}
+	log.Debugf("Fetched %d issues of %s from Gitea", len(issues), repo.fullName())
+
+	workItems := &WorkItems{Repository: repo.fullName(), Items: []workItem{}}
+	for _, issue := range issues {
+		workItems.Items = append(workItems.Items, issue.workItem())
+	}
+	return workItems, nil
+}
+
+// workItem converts the issue into a work item.
+func (issue giteaIssue) workItem() workItem {
+	item := workItem{
+		Url:       issue.HTMLURL,
+		Title:     issue.Title,
+		State:     stateOpen,
+		CreatedAt: issue.CreatedAt,
+		Labels:    []string{},
+		Columns:   []boardColumn{},
+		Log:       []itemEvent{},
+	}
+	if issue.State == "closed" {
+		item.State = stateClosed
+		item.ClosedAt = issue.ClosedAt
+	}
+	for _, label := range issue.Labels {
+		item.Labels = append(item.Labels, label.Name)
+	}
+	if item.State == stateClosed {
+		item.Log = append(item.Log, itemEvent{Type: eventClosed, CreatedAt: item.ClosedAt})
+	}
+	item.sortLog()
+	return item
+}
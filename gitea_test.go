@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFetchGiteaRepository(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	// The instance returns a single issue per page, fewer than requested
+	pages := []string{
+		`[{"number": 1, "title": "Closed", "state": "closed", "created_at": "2019-06-01T10:00:00Z",
+		   "closed_at": "2019-06-05T10:00:00Z", "html_url": "https://codeberg.org/brejoc/test/issues/1",
+		   "labels": [{"name": "bug"}]}]`,
+		`[{"number": 2, "title": "Open", "state": "open", "created_at": "2019-06-02T10:00:00Z",
+		   "closed_at": null, "html_url": "https://codeberg.org/brejoc/test/issues/2", "labels": []}]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token secret" {
+			t.Errorf("Expected the token to be sent")
+		}
+		if r.URL.Path != "/api/v1/repos/brejoc/test/issues" || r.URL.Query().Get("limit") != "50" {
+			t.Errorf("Unexpected request: %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page := 0
+		fmt.Sscan(r.URL.Query().Get("page"), &page)
+		if page < 1 || page > len(pages) {
+			t.Errorf("Unexpected request of page %d", page)
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Header().Set("X-Total-Count", "2")
+		w.Write([]byte(pages[page-1]))
+	}))
+	defer server.Close()
+
+	defer os.Setenv("GITEA_TOKEN", os.Getenv("GITEA_TOKEN"))
+	os.Setenv("GITEA_TOKEN", "secret")
+
+	got, err := fetchGiteaRepository(repository{Source: "forgejo", BaseURL: server.URL, Owner: "brejoc", Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Items) != 2 {
+		t.Fatalf("Expected 2 issues, but got %d", len(got.Items))
+	}
+
+	want := workItem{
+		Url:       "https://codeberg.org/brejoc/test/issues/1",
+		Title:     "Closed",
+		State:     stateClosed,
+		CreatedAt: day(1),
+		ClosedAt:  day(5),
+		Labels:    []string{"bug"},
+		Columns:   []boardColumn{},
+		Log:       []itemEvent{{Type: eventClosed, CreatedAt: day(5)}},
+	}
+	if !reflect.DeepEqual(got.Items[0], want) {
+		t.Errorf("Got %v, but expected %v", got.Items[0], want)
+	}
+
+	open := got.Items[1]
+	if open.State != stateOpen || len(open.Columns) != 0 || len(open.Log) != 0 {
+		t.Errorf("Expected issue 2 to be open on no board without events, but got %v", open)
+	}
+}
+
+func TestGiteaGetAll(t *testing.T) {
+	tests := []struct {
+		name   string
+		header func(w http.ResponseWriter, page int)
+		want   int
+	}{
+		{"total count", func(w http.ResponseWriter, page int) { w.Header().Set("X-Total-Count", "3") }, 3},
+		{"link", func(w http.ResponseWriter, page int) {
+			if page < 3 {
+				w.Header().Set("Link", fmt.Sprintf(`<https://codeberg.org/?page=%d>; rel="next"`, page+1))
+			} else {
+				w.Header().Set("Link", `<https://codeberg.org/?page=1>; rel="first"`)
+			}
+		}, 3},
+		// Without either header the first empty page ends the pages
+		{"empty page", func(w http.ResponseWriter, page int) {}, 4},
+	}
+	for _, test := range tests {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			page := 0
+			fmt.Sscan(r.URL.Query().Get("page"), &page)
+			test.header(w, page)
+			if page > 3 {
+				w.Write([]byte(`[]`))
+				return
+			}
+			fmt.Fprintf(w, `[{"number": %d}]`, page)
+		}))
+
+		issues := []giteaIssue{}
+		issuePage := []giteaIssue{}
+		err := giteaRepository{}.getAll(server.URL+"/issues?state=all", &issuePage, func() int {
+			issues = append(issues, issuePage...)
+			return len(issuePage)
+		})
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(issues) != 3 || requests != test.want {
+			t.Errorf("Expected 3 issues with %d requests with the %s, but got %d with %d requests",
+				test.want, test.name, len(issues), requests)
+		}
+	}
+}
@@ -2,7 +2,14 @@ package main
 
 import (
 	"context"
-	"os"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 
@@ -10,14 +17,19 @@ import (
 	"golang.org/x/oauth2"
 )
 
-// QueryPages holds the multiple pages (Query) we can get from Github.
+// QueryPages holds the multiple pages (Query) we can get from Github
+// for a single repository.
 type QueryPages struct {
-	Queries []Query
+	Repository string
+	Queries    []Query
 }
 
 type project struct {
 	Name githubv4.String
 }
+type projectV2 struct {
+	Title githubv4.String
+}
 type pageInfo struct {
 	StartCursor githubv4.String
 	EndCursor   githubv4.String
@@ -33,15 +45,338 @@ type movedEvent struct {
 	ProjectColumnName         githubv4.String
 	CreatedAt                 githubv4.DateTime
 }
+type addedV2Event struct {
+	Project   projectV2
+	CreatedAt githubv4.DateTime
+}
+type statusChangedEvent struct {
+	Project        projectV2
+	PreviousStatus githubv4.String
+	Status         githubv4.String
+	CreatedAt      githubv4.DateTime
+}
+type labelEvent struct {
+	Label struct {
+		Name githubv4.String
+	}
+	CreatedAt githubv4.DateTime
+}
+type stateEvent struct {
+	CreatedAt githubv4.DateTime
+}
+type assignedEvent struct {
+	Assignee struct {
+		Actor struct {
+			Login githubv4.String
+		} `graphql:"...on Actor"`
+	}
+	CreatedAt githubv4.DateTime
+}
 type node struct {
-	Typename   string     `graphql:"__typename"`
-	AddedEvent addedEvent `graphql:"...on AddedToProjectEvent"`
-	MovedEvent movedEvent `graphql:"...on MovedColumnsInProjectEvent"`
+	Typename              string             `graphql:"__typename"`
+	AddedEvent            addedEvent         `graphql:"...on AddedToProjectEvent"`
+	MovedEvent            movedEvent         `graphql:"...on MovedColumnsInProjectEvent"`
+	AddedV2Event          addedV2Event       `graphql:"...on AddedToProjectV2Event"`
+	StatusChangedEvent    statusChangedEvent `graphql:"...on ProjectV2ItemStatusChangedEvent"`
+	LabeledEvent          labelEvent         `graphql:"...on LabeledEvent"`
+	UnlabeledEvent        labelEvent         `graphql:"...on UnlabeledEvent"`
+	ClosedEvent           stateEvent         `graphql:"...on ClosedEvent"`
+	ReopenedEvent         stateEvent         `graphql:"...on ReopenedEvent"`
+	AssignedEvent         assignedEvent      `graphql:"...on AssignedEvent"`
+	RemovedEvent          addedEvent         `graphql:"...on RemovedFromProjectEvent"`
+	ConvertedToIssueEvent addedEvent         `graphql:"...on ConvertedNoteToIssueEvent"`
+}
+type rateLimit struct {
+	Cost      int
+	Remaining int
+	ResetAt   githubv4.DateTime
 }
 type queryTimelineItems struct {
 	PageInfo pageInfo
 	Nodes    []node
 }
+type fieldValue struct {
+	SingleSelect struct {
+		Name  githubv4.String
+		Field struct {
+			SingleSelectField struct {
+				Name githubv4.String
+			} `graphql:"...on ProjectV2SingleSelectField"`
+		}
+	} `graphql:"...on ProjectV2ItemFieldSingleSelectValue"`
+}
+type queryFieldValues struct {
+	PageInfo pageInfo
+	Nodes    []fieldValue
+}
+type projectItem struct {
+	Id          githubv4.ID
+	Project     projectV2
+	FieldValues queryFieldValues `graphql:"fieldValues(first: 20)"`
+}
+
+type queryProjectCards struct {
+	PageInfo pageInfo
+	Nodes    []struct {
+		Column struct {
+			Name    githubv4.String
+			Project struct {
+				Name githubv4.String
+			}
+		}
+	}
+}
+type queryProjectItems struct {
+	PageInfo pageInfo
+	Nodes    []projectItem
+}
+type queryLabels struct {
+	PageInfo pageInfo
+	Nodes    []struct {
+		Name githubv4.String
+	}
+}
+
+// issue is a single issue with its timeline, project cards,
+// Projects (v2) items and labels. The page sizes keep a page of 100 issues
+// below the limit of 500,000 nodes per query, further pages are fetched with
+// follow-up queries.
+type issue struct {
+	Id            githubv4.ID
+	CreatedAt     githubv4.DateTime
+	UpdatedAt     githubv4.DateTime
+	ClosedAt      githubv4.DateTime
+	Title         githubv4.String
+	Url           githubv4.URI
+	State         githubv4.StatusState
+	StateReason   githubv4.String
+	TimelineItems queryTimelineItems `graphql:"timelineItems(itemTypes: [ADDED_TO_PROJECT_EVENT, MOVED_COLUMNS_IN_PROJECT_EVENT, ADDED_TO_PROJECT_V2_EVENT, PROJECT_V2_ITEM_STATUS_CHANGED_EVENT, LABELED_EVENT, UNLABELED_EVENT, CLOSED_EVENT, REOPENED_EVENT, ASSIGNED_EVENT, REMOVED_FROM_PROJECT_EVENT, CONVERTED_NOTE_TO_ISSUE_EVENT], first: 250)"`
+	ProjectCards  queryProjectCards  `graphql:"projectCards(first: 100)"`
+	ProjectItems  queryProjectItems  `graphql:"projectItems(first: 10)"`
+	Labels        queryLabels        `graphql:"labels(first: 100)"`
+}
+
+// timelineQuery fetches a further page of the timeline of an issue.
+type timelineQuery struct {
+	Node struct {
+		Issue struct {
+			TimelineItems queryTimelineItems `graphql:"timelineItems(itemTypes: [ADDED_TO_PROJECT_EVENT, MOVED_COLUMNS_IN_PROJECT_EVENT, ADDED_TO_PROJECT_V2_EVENT, PROJECT_V2_ITEM_STATUS_CHANGED_EVENT, LABELED_EVENT, UNLABELED_EVENT, CLOSED_EVENT, REOPENED_EVENT, ASSIGNED_EVENT, REMOVED_FROM_PROJECT_EVENT, CONVERTED_NOTE_TO_ISSUE_EVENT], first: 250, after: $cursor)"`
+		} `graphql:"...on Issue"`
+	} `graphql:"node(id: $id)"`
+	RateLimit rateLimit
+}
+
+// projectCardsQuery fetches a further page of the project cards of an issue.
+type projectCardsQuery struct {
+	Node struct {
+		Issue struct {
+			ProjectCards queryProjectCards `graphql:"projectCards(first: 100, after: $cursor)"`
+		} `graphql:"...on Issue"`
+	} `graphql:"node(id: $id)"`
+	RateLimit rateLimit
+}
+
+// projectItemsQuery fetches a further page of the Projects (v2) items of an issue.
+type projectItemsQuery struct {
+	Node struct {
+		Issue struct {
+			ProjectItems queryProjectItems `graphql:"projectItems(first: 100, after: $cursor)"`
+		} `graphql:"...on Issue"`
+	} `graphql:"node(id: $id)"`
+	RateLimit rateLimit
+}
+
+// fieldValuesQuery fetches a further page of the field values of a Projects (v2) item.
+type fieldValuesQuery struct {
+	Node struct {
+		ProjectItem struct {
+			FieldValues queryFieldValues `graphql:"fieldValues(first: 100, after: $cursor)"`
+		} `graphql:"...on ProjectV2Item"`
+	} `graphql:"node(id: $id)"`
+	RateLimit rateLimit
+}
+
+// labelsQuery fetches a further page of the labels of an issue.
+type labelsQuery struct {
+	Node struct {
+		Issue struct {
+			Labels queryLabels `graphql:"labels(first: 100, after: $cursor)"`
+		} `graphql:"...on Issue"`
+	} `graphql:"node(id: $id)"`
+	RateLimit rateLimit
+}
+
+// noStatusColumn is the column of the items on Projects (v2) boards without
+// a value in the status field, as Github shows them.
+const noStatusColumn = "No Status"
+
+// statusColumn returns the column of a status on a Projects (v2) board. Items
+// without a status are in the noStatusColumn, e.g. before their first status.
+func statusColumn(status githubv4.String) string {
+	if status == "" {
+		return noStatusColumn
+	}
+	return string(status)
+}
+
+// boardColumns returns the configured boards an issue is currently on with
+// its column. Classic boards are read from the project cards, Projects (v2)
+// boards from the status field configured for the board.
+func (i issue) boardColumns() []boardColumn {
+	columns := []boardColumn{}
+	for _, card := range i.ProjectCards.Nodes {
+		boardName := string(card.Column.Project.Name)
+		if b, ok := config.Boards[boardName]; ok && !b.isProjectV2() {
+			columns = append(columns, boardColumn{Board: boardName, Column: string(card.Column.Name)})
+		}
+	}
+	for _, item := range i.ProjectItems.Nodes {
+		boardName := string(item.Project.Title)
+		b, ok := config.Boards[boardName]
+		if !ok || !b.isProjectV2() {
+			continue
+		}
+		column := noStatusColumn
+		for _, value := range item.FieldValues.Nodes {
+			if strings.ToLower(string(value.SingleSelect.Field.SingleSelectField.Name)) == strings.ToLower(b.statusField()) {
+				column = string(value.SingleSelect.Name)
+				break
+			}
+		}
+		if column == noStatusColumn {
+			log.Debugf("Issue %s has no value in the field %s on board %s", i.Url.String(), b.statusField(), boardName)
+		}
+		columns = append(columns, boardColumn{Board: boardName, Column: column})
+	}
+	return columns
+}
+
+// boardEvents returns the events of an issue on the given board in the order they happened. For classic boards
+// these are the project events and for Projects (v2) boards the changes of the status field.
+func boardEvents(timelineItems queryTimelineItems, boardName string) []columnEvent {
+	events := []columnEvent{}
+	isProjectV2 := config.Boards[boardName].isProjectV2()
+
+	for _, event := range timelineItems.Nodes {
+		if isProjectV2 {
+			switch event.Typename {
+			case "AddedToProjectV2Event":
+				if strings.ToLower(string(event.AddedV2Event.Project.Title)) == strings.ToLower(boardName) {
+					events = append(events, columnEvent{Added: true, CreatedAt: event.AddedV2Event.CreatedAt.Time})
+				}
+			case "ProjectV2ItemStatusChangedEvent":
+				if strings.ToLower(string(event.StatusChangedEvent.Project.Title)) == strings.ToLower(boardName) {
+					events = append(events, columnEvent{
+						PreviousColumn: statusColumn(event.StatusChangedEvent.PreviousStatus),
+						Column:         statusColumn(event.StatusChangedEvent.Status),
+						CreatedAt:      event.StatusChangedEvent.CreatedAt.Time,
+					})
+				}
+			}
+			continue
+		}
+
+		if event.Typename == "MovedColumnsInProjectEvent" {
+			if strings.ToLower(string(event.MovedEvent.Project.Name)) == strings.ToLower(boardName) {
+				events = append(events, columnEvent{
+					PreviousColumn: string(event.MovedEvent.PreviousProjectColumnName),
+					Column:         string(event.MovedEvent.ProjectColumnName),
+					CreatedAt:      event.MovedEvent.CreatedAt.Time,
+				})
+			}
+		} else if event.Typename == "AddedToProjectEvent" &&
+			strings.ToLower(string(event.AddedEvent.Project.Name)) == strings.ToLower(boardName) {
+			events = append(events, columnEvent{Added: true, CreatedAt: event.AddedEvent.CreatedAt.Time})
+		}
+	}
+	return events
+}
+
+// workItem converts the issue into a work item with its events on the configured boards and its lifecycle events.
+func (i issue) workItem() workItem {
+	item := workItem{
+		Url:         i.Url.String(),
+		Title:       string(i.Title),
+		State:       string(i.State),
+		CreatedAt:   i.CreatedAt.Time,
+		ClosedAt:    i.ClosedAt.Time,
+		StateReason: string(i.StateReason),
+		Labels:      []string{},
+		Columns:     i.boardColumns(),
+		Log:         []itemEvent{},
+	}
+	for _, label := range i.Labels.Nodes {
+		item.Labels = append(item.Labels, string(label.Name))
+	}
+	for boardName := range config.Boards {
+		item.addBoardEvents(boardName, boardEvents(i.TimelineItems, boardName))
+	}
+
+	// configuredBoard returns the name of the configured classic board with the given name
+	configuredBoard := func(name githubv4.String) (string, bool) {
+		for boardName, b := range config.Boards {
+			if !b.isProjectV2() && strings.ToLower(boardName) == strings.ToLower(string(name)) {
+				return boardName, true
+			}
+		}
+		return "", false
+	}
+	for _, event := range i.TimelineItems.Nodes {
+		switch event.Typename {
+		case "LabeledEvent":
+			item.Log = append(item.Log, itemEvent{Type: eventLabeled, CreatedAt: event.LabeledEvent.CreatedAt.Time,
+				Label: string(event.LabeledEvent.Label.Name)})
+		case "UnlabeledEvent":
+			item.Log = append(item.Log, itemEvent{Type: eventUnlabeled, CreatedAt: event.UnlabeledEvent.CreatedAt.Time,
+				Label: string(event.UnlabeledEvent.Label.Name)})
+		case "ClosedEvent":
+			item.Log = append(item.Log, itemEvent{Type: eventClosed, CreatedAt: event.ClosedEvent.CreatedAt.Time})
+		case "ReopenedEvent":
+			item.Log = append(item.Log, itemEvent{Type: eventReopened, CreatedAt: event.ReopenedEvent.CreatedAt.Time})
+		case "AssignedEvent":
+			item.Log = append(item.Log, itemEvent{Type: eventAssigned, CreatedAt: event.AssignedEvent.CreatedAt.Time,
+				Assignee: string(event.AssignedEvent.Assignee.Actor.Login)})
+		case "RemovedFromProjectEvent":
+			if boardName, ok := configuredBoard(event.RemovedEvent.Project.Name); ok {
+				item.Log = append(item.Log, itemEvent{Type: eventRemoved, CreatedAt: event.RemovedEvent.CreatedAt.Time,
+					Board: boardName})
+			}
+		case "ConvertedNoteToIssueEvent":
+			if boardName, ok := configuredBoard(event.ConvertedToIssueEvent.Project.Name); ok {
+				item.Log = append(item.Log, itemEvent{Type: eventConverted,
+					CreatedAt: event.ConvertedToIssueEvent.CreatedAt.Time, Board: boardName})
+			}
+		}
+	}
+	item.sortLog()
+	return item
+}
+
+// workItems converts the issues of all pages into work items.
+func (queryPages *QueryPages) workItems() *WorkItems {
+	workItems := &WorkItems{Repository: queryPages.Repository, Items: []workItem{}}
+	for _, query := range queryPages.Queries {
+		for _, issue := range query.Repository.Issues.Nodes {
+			workItems.Items = append(workItems.Items, issue.workItem())
+		}
+	}
+	return workItems
+}
+
+// githubSource fetches the issues of repositories on Github.
+type githubSource struct{}
+
+func (githubSource) fetch(repos []repository) ([]*WorkItems, error) {
+	results, err := FetchAllIssues(repos)
+	if err != nil {
+		return nil, err
+	}
+	workItems := []*WorkItems{}
+	for _, queryPages := range results {
+		workItems = append(workItems, queryPages.workItems())
+	}
+	return workItems, nil
+}
 
 // Query is used to perform the Graphql query and also
 // holds the results afterwards.
@@ -54,60 +389,191 @@ type Query struct {
 				EndCursor   githubv4.String
 				HasNextPage bool
 			}
-			Nodes []struct {
-				CreatedAt     githubv4.DateTime
-				ClosedAt      githubv4.DateTime
-				Title         githubv4.String
-				Url           githubv4.URI
-				State         githubv4.StatusState
-				TimelineItems queryTimelineItems `graphql:"timelineItems(itemTypes: [ADDED_TO_PROJECT_EVENT, MOVED_COLUMNS_IN_PROJECT_EVENT], first: 250)"`
-				ProjectCards  struct {
-					Nodes []struct {
-						Column struct {
-							Name    githubv4.String
-							Project struct {
-								Name githubv4.String
-							}
-						}
-					}
-				} `graphql:"projectCards"`
-				Labels struct {
-					Nodes []struct {
-						Name githubv4.String
-					}
-				} `graphql:"labels(first: 100)"`
-			}
-		} `graphql:"issues(first: 100, after: $startCursor)"`
+			Nodes []issue
+		} `graphql:"issues(first: 100, after: $startCursor, filterBy: {since: $since}, orderBy: {field: UPDATED_AT, direction: ASC})"`
 	} `graphql:"repository(owner: $owner, name: $repo)"`
+	RateLimit rateLimit
+}
+
+// partialFetch is a fetch of the issues of a repository that is in progress
+// or failed. A failed fetch is resumed from the last successfully fetched page.
+type partialFetch struct {
+	// Since is when the issues were updated since. It is nil for all issues.
+	Since *githubv4.DateTime
+	// StartedAt is when the first attempt of the fetch started
+	StartedAt time.Time
+	Cursor    githubv4.String
+	Queries   []Query
+}
+
+// newPartialFetch returns a partial fetch without any pages, starting now.
+func newPartialFetch(since *githubv4.DateTime) *partialFetch {
+	return &partialFetch{Since: since, StartedAt: time.Now()}
+}
+
+// githubTransport returns the HTTP transport for Github with the configured
+// proxy and CA bundle. Without proxy the proxy of the environment is used.
+func githubTransport() (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.Github.Proxy != "" {
+		proxyURL, err := url.Parse(config.Github.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Github proxy %q: %s", config.Github.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	if config.Github.CaFile != "" {
+		pem, err := ioutil.ReadFile(config.Github.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("not able to read the Github CA bundle: %s", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in the Github CA bundle %s", config.Github.CaFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+	return transport, nil
+}
+
+// newGithubClient returns a client for the configured Github GraphQL endpoint,
+// which is the one of github.com by default.
+func newGithubClient() (*githubv4.Client, error) {
+	transport, err := githubTransport()
+	if err != nil {
+		return nil, err
+	}
+	src, err := githubTokenSource()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+	httpClient := oauth2.NewClient(ctx, src)
+	if config.Github.Endpoint == "" {
+		return githubv4.NewClient(httpClient), nil
+	}
+	return githubv4.NewEnterpriseClient(config.Github.Endpoint, httpClient), nil
 }
 
-// FetchAllIssues fetches all of the issues from Github and returns
-// a pointer to the query struct.
-func FetchAllIssues() (*QueryPages, error) {
-	queryPages := QueryPages{}
+// partialFetches holds the failed fetches by repository if no cache file is
+// configured, so that they are resumed in the next run.
+var partialFetches = map[string]*partialFetch{}
+
+// FetchAllIssues fetches all of the issues of the given repositories
+// from Github and returns the pages of every repository.
+// If a cache file is configured, only the issues updated since the last run
+// are fetched and merged into the cached issues. The full fetches on the
+// refresh interval replace the cached issues.
+// Fetches that fail are resumed from the last successful page by the next call.
+func FetchAllIssues(repos []repository) ([]*QueryPages, error) {
+	client, err := newGithubClient()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.CacheFile == "" {
+		results := []*QueryPages{}
+		for _, repo := range repos {
+			partial, ok := partialFetches[repo.fullName()]
+			if !ok {
+				partial = newPartialFetch(nil)
+				partialFetches[repo.fullName()] = partial
+			}
+			queryPages, err := fetchRepositoryIssues(client, repo, partial)
+			if err != nil {
+				return nil, err
+			}
+			delete(partialFetches, repo.fullName())
+			results = append(results, queryPages)
+		}
+		return results, nil
+	}
+
+	cache, err := loadIssueCache(config.CacheFile)
+	if err != nil {
+		return nil, err
+	}
+	results := []*QueryPages{}
+	for _, repo := range repos {
+		cachedRepo := cache.repository(repo.fullName())
+
+		if cachedRepo.Partial == nil {
+			since := cachedRepo.since(time.Now())
+			if since != nil {
+				log.Debugf("Fetching issues of %s updated since %s", repo.fullName(), cachedRepo.FetchedAt)
+			} else {
+				log.Debugf("Fetching all issues of %s", repo.fullName())
+			}
+			cachedRepo.Partial = newPartialFetch(since)
+		}
+		queryPages, err := fetchRepositoryIssues(client, repo, cachedRepo.Partial)
+		if err != nil {
+			// Keep the pages fetched so far for the next run
+			if err := cache.save(config.CacheFile); err != nil {
+				log.Error("Not able to save the issue cache: ", err)
+			}
+			return nil, err
+		}
+		cachedRepo.FetchedAt = cachedRepo.Partial.StartedAt
+		if cachedRepo.Partial.Since == nil {
+			cachedRepo.replace(queryPages)
+			cachedRepo.RefreshedAt = cachedRepo.Partial.StartedAt
+		} else {
+			cachedRepo.merge(queryPages)
+		}
+		cachedRepo.Partial = nil
+		results = append(results, cachedRepo.queryPages(repo.fullName()))
+	}
+	if err := cache.save(config.CacheFile); err != nil {
+		log.Error("Not able to save the issue cache: ", err)
+	}
+	return results, nil
+}
 
-	src := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: os.Getenv("GITHUB_TOKEN")},
-	)
-	httpClient := oauth2.NewClient(context.Background(), src)
-	client := githubv4.NewClient(httpClient)
+// fetchRepositoryIssues fetches the issues of a single repository. The fetch
+// continues after the pages already held by partial and every successfully
+// fetched page is added to it, so that a failed fetch can be resumed.
+func fetchRepositoryIssues(client *githubv4.Client, repo repository, partial *partialFetch) (*QueryPages, error) {
+	queryPages := QueryPages{Repository: repo.fullName()}
 
 	variables := map[string]interface{}{
 		"startCursor": (*githubv4.String)(nil),
-		"owner":       githubv4.String(config.Repository.Owner),
-		"repo":        githubv4.String(config.Repository.Name),
+		"since":       partial.Since,
+		"owner":       githubv4.String(repo.Owner),
+		"repo":        githubv4.String(repo.Name),
+	}
+	if partial.Cursor != "" {
+		log.Infof("Resuming the fetch of %s after %d pages", queryPages.Repository, len(partial.Queries))
+		variables["startCursor"] = githubv4.NewString(partial.Cursor)
 	}
 
-	pageCount := 0
+	pageCount := len(partial.Queries)
+	followUpCount := 0
 	for {
 		pageCount++
-		log.Debug("Fetching page: ", pageCount)
+		log.Debugf("Fetching page %d of %s", pageCount, queryPages.Repository)
 		query := Query{}
-		err := client.Query(context.Background(), &query, variables)
+		err := queryWithRetries(client, &query, variables)
 		if err != nil {
 			log.Error(err)
 			return nil, err
 		}
+		throttle(query.RateLimit)
+
+		// Fetch what did not fit into the page of the issues
+		for i := range query.Repository.Issues.Nodes {
+			followedUp, err := fetchRemainingPages(client, &query.Repository.Issues.Nodes[i])
+			if err != nil {
+				log.Error(err)
+				return nil, err
+			}
+			if followedUp {
+				followUpCount++
+			}
+		}
 
 		log.Debug("resultCount:", query.Repository.Issues.TotalCount)
 		log.Debug("      nodes:", query.Repository.Issues.Nodes)
@@ -126,11 +592,120 @@ func FetchAllIssues() (*QueryPages, error) {
 				log.Debug("       Column:", ghColumn.Column.Name)
 			}
 		}
-		queryPages.Queries = append(queryPages.Queries, query)
+		partial.Queries = append(partial.Queries, query)
 		if query.Repository.Issues.PageInfo.HasNextPage == true {
-			variables["startCursor"] = githubv4.NewString(query.Repository.Issues.PageInfo.EndCursor)
+			partial.Cursor = query.Repository.Issues.PageInfo.EndCursor
+			variables["startCursor"] = githubv4.NewString(partial.Cursor)
 			continue
 		}
+		queryPages.Queries = dropOutdatedIssues(partial.Queries)
+		log.Infof("%d issues of %s needed follow-up queries", followUpCount, queryPages.Repository)
+		followUpIssuesGauge.WithLabelValues(queryPages.Repository).Set(float64(followUpCount))
 		return &queryPages, nil
 	}
 }
+
+// dropOutdatedIssues removes issues that are contained in a later page again from the pages. Issues are ordered by
+// their last update, so an issue updated while a fetch is resumed shows up once more at the end.
+func dropOutdatedIssues(queries []Query) []Query {
+	seen := map[string]bool{}
+	for i := len(queries) - 1; i >= 0; i-- {
+		nodes := []issue{}
+		for _, issue := range queries[i].Repository.Issues.Nodes {
+			if !seen[issue.Url.String()] {
+				nodes = append(nodes, issue)
+			}
+		}
+		for _, issue := range nodes {
+			seen[issue.Url.String()] = true
+		}
+		queries[i].Repository.Issues.Nodes = nodes
+	}
+	return queries
+}
+
+// sleep pauses the fetching. It is replaced in tests.
+var sleep = time.Sleep
+
+// throttle updates the rate limit metrics with the rate limit returned by a query and pauses until the rate limit
+// is reset when the remaining points are below the configured threshold.
+func throttle(limit rateLimit) {
+	// Rate limits are disabled on some Github Enterprise instances
+	if limit.ResetAt.IsZero() {
+		return
+	}
+	rateLimitRemainingGauge.Set(float64(limit.Remaining))
+	queryCostCounter.Add(float64(limit.Cost))
+	log.Debugf("Query cost: %d, remaining rate limit: %d, reset at: %s", limit.Cost, limit.Remaining, limit.ResetAt)
+
+	if limit.Remaining >= config.Github.rateLimitThreshold() {
+		return
+	}
+	if wait := limit.ResetAt.Sub(time.Now()); wait > 0 {
+		log.Warnf("Only %d points of the Github rate limit left, pausing until %s", limit.Remaining, limit.ResetAt)
+		sleep(wait)
+	}
+}
+
+// fetchRemainingPages fetches the remaining pages of the timeline, project cards, Projects (v2) items and labels of
+// an issue and appends them to the issue. It reports whether any follow-up query was needed.
+func fetchRemainingPages(client *githubv4.Client, issue *issue) (bool, error) {
+	followedUp := false
+	variables := func(cursor githubv4.String) map[string]interface{} {
+		followedUp = true
+		return map[string]interface{}{"id": issue.Id, "cursor": githubv4.NewString(cursor)}
+	}
+
+	for issue.TimelineItems.PageInfo.HasNextPage {
+		query := timelineQuery{}
+		if err := queryWithRetries(client, &query, variables(issue.TimelineItems.PageInfo.EndCursor)); err != nil {
+			return followedUp, err
+		}
+		throttle(query.RateLimit)
+		issue.TimelineItems.Nodes = append(issue.TimelineItems.Nodes, query.Node.Issue.TimelineItems.Nodes...)
+		issue.TimelineItems.PageInfo = query.Node.Issue.TimelineItems.PageInfo
+	}
+	for issue.ProjectCards.PageInfo.HasNextPage {
+		query := projectCardsQuery{}
+		if err := queryWithRetries(client, &query, variables(issue.ProjectCards.PageInfo.EndCursor)); err != nil {
+			return followedUp, err
+		}
+		throttle(query.RateLimit)
+		issue.ProjectCards.Nodes = append(issue.ProjectCards.Nodes, query.Node.Issue.ProjectCards.Nodes...)
+		issue.ProjectCards.PageInfo = query.Node.Issue.ProjectCards.PageInfo
+	}
+	for issue.ProjectItems.PageInfo.HasNextPage {
+		query := projectItemsQuery{}
+		if err := queryWithRetries(client, &query, variables(issue.ProjectItems.PageInfo.EndCursor)); err != nil {
+			return followedUp, err
+		}
+		throttle(query.RateLimit)
+		issue.ProjectItems.Nodes = append(issue.ProjectItems.Nodes, query.Node.Issue.ProjectItems.Nodes...)
+		issue.ProjectItems.PageInfo = query.Node.Issue.ProjectItems.PageInfo
+	}
+	// Projects with many fields might hold the status field on a further page
+	for i := range issue.ProjectItems.Nodes {
+		item := &issue.ProjectItems.Nodes[i]
+		for item.FieldValues.PageInfo.HasNextPage {
+			query := fieldValuesQuery{}
+			itemVariables := variables(item.FieldValues.PageInfo.EndCursor)
+			itemVariables["id"] = item.Id
+			if err := queryWithRetries(client, &query, itemVariables); err != nil {
+				return followedUp, err
+			}
+			throttle(query.RateLimit)
+			item.FieldValues.Nodes = append(item.FieldValues.Nodes, query.Node.ProjectItem.FieldValues.Nodes...)
+			item.FieldValues.PageInfo = query.Node.ProjectItem.FieldValues.PageInfo
+		}
+	}
+	for issue.Labels.PageInfo.HasNextPage {
+		query := labelsQuery{}
+		if err := queryWithRetries(client, &query, variables(issue.Labels.PageInfo.EndCursor)); err != nil {
+			return followedUp, err
+		}
+		throttle(query.RateLimit)
+		issue.Labels.Nodes = append(issue.Labels.Nodes, query.Node.Issue.Labels.Nodes...)
+		issue.Labels.PageInfo = query.Node.Issue.Labels.PageInfo
+	}
+	return followedUp, nil
+}
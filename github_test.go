@@ -0,0 +1,414 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brejoc/githubv4"
+)
+
+func TestFetchRemainingPages(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(string(body), "fieldValues(first: 100") && strings.Contains(string(body), `"id":"item1"`) {
+			w.Write([]byte(`{"data": {"node": {"fieldValues": {
+				"pageInfo": {"startCursor": "fields2", "endCursor": "fields2", "hasNextPage": false},
+				"nodes": [{"name": "In progress", "field": {"name": "Status"}}]
+			}}}}`))
+			return
+		}
+		if !strings.Contains(string(body), "timelineItems") || !strings.Contains(string(body), `"cursor":"page1"`) {
+			t.Errorf("Unexpected query: %s", body)
+		}
+		w.Write([]byte(`{"data": {"node": {"timelineItems": {
+			"pageInfo": {"startCursor": "page2", "endCursor": "page2", "hasNextPage": false},
+			"nodes": [{
+				"__typename": "MovedColumnsInProjectEvent",
+				"project": {"name": "test"},
+				"previousProjectColumnName": "Planned",
+				"projectColumnName": "In progress",
+				"createdAt": "2019-06-01T10:00:00Z"
+			}]
+		}}}}`))
+	}))
+	defer server.Close()
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+
+	testIssue := issue{Id: "issue1"}
+	testIssue.TimelineItems.PageInfo = pageInfo{EndCursor: "page1", HasNextPage: true}
+	testIssue.TimelineItems.Nodes = []node{movedNode("test", "Requested", "Planned", time.Now(), 0)}
+
+	followedUp, err := fetchRemainingPages(client, &testIssue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !followedUp || queries != 1 {
+		t.Errorf("Expected one follow-up query, but got %d", queries)
+	}
+	if len(testIssue.TimelineItems.Nodes) != 2 {
+		t.Fatalf("Expected 2 timeline items, but got %d", len(testIssue.TimelineItems.Nodes))
+	}
+	if got := testIssue.TimelineItems.Nodes[1].MovedEvent.ProjectColumnName; got != "In progress" {
+		t.Errorf("Expected the event of the second page, but got a move to %q", got)
+	}
+
+	// Issues without further pages need no follow-up queries
+	followedUp, err = fetchRemainingPages(client, &issue{Id: "issue2"})
+	if err != nil || followedUp || queries != 1 {
+		t.Errorf("Expected no follow-up query, but got %d queries", queries)
+	}
+
+	// The status field of Projects (v2) items might be on a further page of the field values
+	config.Boards["test-v2"] = board{ProjectType: "v2"}
+	item := projectItem{Id: "item1"}
+	item.Project.Title = "test-v2"
+	item.FieldValues.PageInfo = pageInfo{EndCursor: "fields1", HasNextPage: true}
+	testIssue = issue{Id: "issue3"}
+	testIssue.ProjectItems.Nodes = []projectItem{item}
+	if _, err := fetchRemainingPages(client, &testIssue); err != nil {
+		t.Fatal(err)
+	}
+	want := []boardColumn{{Board: "test-v2", Column: "In progress"}}
+	if got := testIssue.boardColumns(); queries != 2 || !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v as columns after %d queries, but expected %v after 2", got, queries, want)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	var slept time.Duration
+	sleep = func(d time.Duration) { slept = d }
+	defer func() { sleep = time.Sleep }()
+
+	resetAt := githubv4.DateTime{Time: time.Now().Add(10 * time.Minute)}
+	throttle(rateLimit{Cost: 1, Remaining: 4000, ResetAt: resetAt})
+	if slept != 0 {
+		t.Errorf("Expected no pause with enough remaining points, but paused for %s", slept)
+	}
+
+	throttle(rateLimit{Cost: 1, Remaining: 50, ResetAt: resetAt})
+	if slept <= 9*time.Minute || slept > 10*time.Minute {
+		t.Errorf("Expected a pause until the rate limit is reset, but paused for %s", slept)
+	}
+}
+
+func TestQueryWithRetries(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	var delays []time.Duration
+	sleep = func(d time.Duration) { delays = append(delays, d) }
+	defer func() { sleep = time.Sleep }()
+
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries++
+		if queries <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"node": {"labels": {"pageInfo": {"hasNextPage": false}, "nodes": [{"name": "bug"}]}}}}`))
+	}))
+	defer server.Close()
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+
+	query := labelsQuery{}
+	err := queryWithRetries(client, &query, map[string]interface{}{"id": githubv4.ID("issue1"), "cursor": githubv4.NewString("page1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queries != 3 || len(query.Node.Issue.Labels.Nodes) != 1 {
+		t.Errorf("Expected the query to succeed on the third attempt, but got %d attempts", queries)
+	}
+	if len(delays) != 2 || delays[0] < 500*time.Millisecond || delays[0] > time.Second ||
+		delays[1] < time.Second || delays[1] > 2*time.Second {
+		t.Errorf("Expected exponentially growing delays, but got %v", delays)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+	rng := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		retry    int
+		maxDelay time.Duration
+	}{
+		{1, time.Second},
+		{3, 4 * time.Second},
+		// The doubling stops at an hour instead of overflowing
+		{13, time.Hour},
+		{100, time.Hour},
+	}
+	for _, test := range tests {
+		if got := retryDelay(test.retry, rng); got < test.maxDelay/2 || got > test.maxDelay {
+			t.Errorf("Expected the delay of retry %d between %s and %s, but got %s",
+				test.retry, test.maxDelay/2, test.maxDelay, got)
+		}
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		message   string
+		transient bool
+	}{
+		{`non-200 OK status code: 502 Bad Gateway body: ""`, true},
+		{`non-200 OK status code: 403 Forbidden body: "You have exceeded a secondary rate limit."`, true},
+		{`non-200 OK status code: 403 Forbidden body: "Resource not accessible by integration"`, false},
+		{`non-200 OK status code: 401 Unauthorized body: ""`, false},
+		{`Post "https://api.github.com/graphql": net/http: request canceled (Client.Timeout exceeded)`, true},
+		{`Could not resolve to a Repository with the name 'brejoc/unknown'.`, false},
+	}
+	for _, test := range tests {
+		if got := isTransientError(errors.New(test.message)); got != test.transient {
+			t.Errorf("Expected %q to be transient: %v, but got %v", test.message, test.transient, got)
+		}
+	}
+}
+
+func TestFetchRepositoryIssuesResumes(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	failing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(string(body), `"startCursor":"page1"`):
+			if failing {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"data": {"repository": {"issues": {
+				"totalCount": 2, "pageInfo": {"endCursor": "page2", "hasNextPage": false},
+				"nodes": [{"url": "https://github.com/brejoc/test/issues/2"}, {"url": "https://github.com/brejoc/test/issues/1"}]
+			}}}}`))
+		case strings.Contains(string(body), `"startCursor":null`):
+			w.Write([]byte(`{"data": {"repository": {"issues": {
+				"totalCount": 2, "pageInfo": {"endCursor": "page1", "hasNextPage": true},
+				"nodes": [{"url": "https://github.com/brejoc/test/issues/1"}]
+			}}}}`))
+		default:
+			t.Errorf("Unexpected query: %s", body)
+		}
+	}))
+	defer server.Close()
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+
+	repo, _ := config.repository("brejoc/test")
+	partial := newPartialFetch(nil)
+	if _, err := fetchRepositoryIssues(client, repo, partial); err == nil {
+		t.Fatal("Expected the fetch to fail")
+	}
+	if len(partial.Queries) != 1 || partial.Cursor != "page1" {
+		t.Fatalf("Expected the first page to be kept, but got %d pages and cursor %q", len(partial.Queries), partial.Cursor)
+	}
+
+	failing = false
+	queryPages, err := fetchRepositoryIssues(client, repo, partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queryPages.Queries) != 2 {
+		t.Fatalf("Expected 2 pages, but got %d", len(queryPages.Queries))
+	}
+	// Issue 1 was updated in the meantime and is only kept in the second page
+	if len(queryPages.Queries[0].Repository.Issues.Nodes) != 0 || len(queryPages.Queries[1].Repository.Issues.Nodes) != 2 {
+		t.Errorf("Expected the outdated version of issue 1 to be dropped")
+	}
+}
+
+func TestNewGithubClient(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	response := `{"data": {"node": {"labels": {"pageInfo": {"hasNextPage": false}, "nodes": [{"name": "bug"}]}}}}`
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			t.Errorf("Unexpected request: %s", r.URL)
+		}
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	// The self-signed certificate of the server is only trusted with the CA bundle
+	caFile, err := ioutil.TempFile("", "filtra-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+	pem.Encode(caFile, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	caFile.Close()
+
+	defer os.Setenv("GITHUB_TOKEN", os.Getenv("GITHUB_TOKEN"))
+	os.Setenv("GITHUB_TOKEN", "secret")
+
+	config.Github.Endpoint = server.URL + "/api/graphql"
+	client, err := newGithubClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := labelsQuery{}
+	variables := map[string]interface{}{"id": githubv4.ID("issue1"), "cursor": githubv4.NewString("page1")}
+	if err := client.Query(context.Background(), &query, variables); err == nil {
+		t.Error("Expected the certificate of the server not to be trusted without CA bundle")
+	}
+
+	config.Github.CaFile = caFile.Name()
+	if client, err = newGithubClient(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Query(context.Background(), &query, variables); err != nil {
+		t.Fatal(err)
+	}
+	if len(query.Node.Issue.Labels.Nodes) != 1 {
+		t.Errorf("Expected the labels from the configured endpoint, but got %v", query.Node.Issue.Labels.Nodes)
+	}
+
+	// Requests go through the configured proxy
+	proxied := false
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.Host == "github.example.com"
+		w.Write([]byte(response))
+	}))
+	defer proxy.Close()
+	config.Github.Endpoint = "http://github.example.com/api/graphql"
+	config.Github.Proxy = proxy.URL
+	if client, err = newGithubClient(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Query(context.Background(), &query, variables); err != nil || !proxied {
+		t.Errorf("Expected the query to go through the proxy, but got %v", err)
+	}
+}
+
+func TestIssueWorkItemLog(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": {"node": {"timelineItems": {
+			"pageInfo": {"hasNextPage": false},
+			"nodes": [
+				{"__typename": "ConvertedNoteToIssueEvent", "project": {"name": "test"}, "createdAt": "2019-06-01T10:00:00Z"},
+				{"__typename": "LabeledEvent", "label": {"name": "bug"}, "createdAt": "2019-06-02T10:00:00Z"},
+				{"__typename": "AssignedEvent", "assignee": {"login": "brejoc"}, "createdAt": "2019-06-03T10:00:00Z"},
+				{"__typename": "MovedColumnsInProjectEvent", "project": {"name": "test"},
+				 "previousProjectColumnName": "Planned", "projectColumnName": "In progress", "createdAt": "2019-06-04T10:00:00Z"},
+				{"__typename": "ClosedEvent", "createdAt": "2019-06-05T10:00:00Z"},
+				{"__typename": "ReopenedEvent", "createdAt": "2019-06-06T10:00:00Z"},
+				{"__typename": "UnlabeledEvent", "label": {"name": "bug"}, "createdAt": "2019-06-07T10:00:00Z"},
+				{"__typename": "RemovedFromProjectEvent", "project": {"name": "test"}, "createdAt": "2019-06-08T10:00:00Z"}
+			]
+		}}}}`))
+	}))
+	defer server.Close()
+	client := githubv4.NewEnterpriseClient(server.URL, server.Client())
+
+	query := timelineQuery{}
+	if err := client.Query(context.Background(), &query, map[string]interface{}{
+		"id": githubv4.ID("issue1"), "cursor": githubv4.NewString("")}); err != nil {
+		t.Fatal(err)
+	}
+	issueURL, _ := url.Parse("https://github.com/brejoc/filtra/issues/1")
+	testIssue := issue{Url: githubv4.URI{URL: issueURL}}
+	testIssue.TimelineItems = query.Node.Issue.TimelineItems
+	item := testIssue.workItem()
+
+	want := []itemEvent{
+		{Type: eventConverted, CreatedAt: day(1), Board: "test"},
+		{Type: eventLabeled, CreatedAt: day(2), Label: "bug"},
+		{Type: eventAssigned, CreatedAt: day(3), Assignee: "brejoc"},
+		{Type: eventMoved, CreatedAt: day(4), Board: "test", PreviousColumn: "Planned", Column: "In progress"},
+		{Type: eventClosed, CreatedAt: day(5)},
+		{Type: eventReopened, CreatedAt: day(6)},
+		{Type: eventUnlabeled, CreatedAt: day(7), Label: "bug"},
+		{Type: eventRemoved, CreatedAt: day(8), Board: "test"},
+	}
+	if !reflect.DeepEqual(item.Log, want) {
+		t.Errorf("Got %v as log, but expected %v", item.Log, want)
+	}
+
+	// Issues converted from notes are on the board since then
+	wantEvents := []columnEvent{
+		{Added: true, CreatedAt: day(1)},
+		{PreviousColumn: "Planned", Column: "In progress", CreatedAt: day(4)},
+	}
+	if got := item.boardEvents("test"); !reflect.DeepEqual(got, wantEvents) {
+		t.Errorf("Got %v as board events, but expected %v", got, wantEvents)
+	}
+	if got := item.events(eventClosed, eventReopened); len(got) != 2 {
+		t.Errorf("Expected a closed and a reopened event, but got %v", got)
+	}
+}
+
+func TestBoardColumnsProjectV2(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+	config.Boards["test-v2"] = board{ProjectType: "v2", StatusField: "Stage"}
+
+	issueURL, _ := url.Parse("https://github.com/brejoc/filtra/issues/1")
+	testIssue := issue{Url: githubv4.URI{URL: issueURL}}
+	item := projectItem{}
+	item.Project.Title = "test-v2"
+	testIssue.ProjectItems.Nodes = []projectItem{item}
+
+	// Items without a value in the status field are still on the board
+	want := []boardColumn{{Board: "test-v2", Column: noStatusColumn}}
+	if got := testIssue.boardColumns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v as columns, but expected %v", got, want)
+	}
+
+	item.FieldValues.Nodes = make([]fieldValue, 2)
+	item.FieldValues.Nodes[0].SingleSelect.Name = "Todo"
+	item.FieldValues.Nodes[0].SingleSelect.Field.SingleSelectField.Name = "Status"
+	item.FieldValues.Nodes[1].SingleSelect.Name = "Review"
+	item.FieldValues.Nodes[1].SingleSelect.Field.SingleSelectField.Name = "stage"
+	testIssue.ProjectItems.Nodes = []projectItem{item}
+
+	want = []boardColumn{{Board: "test-v2", Column: "Review"}}
+	if got := testIssue.boardColumns(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v as columns, but expected %v", got, want)
+	}
+
+	// Before their first status items are in the same column as items without a status
+	changed := node{Typename: "ProjectV2ItemStatusChangedEvent"}
+	changed.StatusChangedEvent.Project.Title = "test-v2"
+	changed.StatusChangedEvent.Status = "In progress"
+	changed.StatusChangedEvent.CreatedAt = githubv4.DateTime{Time: day(2)}
+	added := node{Typename: "AddedToProjectV2Event"}
+	added.AddedV2Event.Project.Title = "test-v2"
+	added.AddedV2Event.CreatedAt = githubv4.DateTime{Time: day(1)}
+	events := boardEvents(queryTimelineItems{Nodes: []node{added, changed}}, "test-v2")
+	if len(events) != 2 || events[1].PreviousColumn != noStatusColumn {
+		t.Fatalf("Expected a move from %s, but got %v", noStatusColumn, events)
+	}
+	// The time without a status is no work in progress
+	stints := calculateColumnStints(events, day(1), "In progress")
+	if got := calculateWipTime(stints, day(3), "test-v2"); got != 24*time.Hour {
+		t.Errorf("Got %s for work in progress time, but expected %s", got, 24*time.Hour)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// appTokenSource creates installation access tokens for a Github App. The
+// tokens are requested with a JSON Web Token signed with the private key of the app.
+type appTokenSource struct {
+	appId          int64
+	installationId int64
+	key            *rsa.PrivateKey
+	// apiURL is the base URL of the REST API
+	apiURL string
+	client *http.Client
+}
+
+// parsePrivateKey parses the PEM encoded RSA private key of a Github App.
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded private key found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is no RSA key")
+	}
+	return rsaKey, nil
+}
+
+// jwt returns a JSON Web Token of the app, valid for 10 minutes.
+func (source *appTokenSource) jwt() (string, error) {
+	issuedAt := time.Now().Add(-time.Minute) // allow for clock drift
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iat": issuedAt.Unix(),
+		"exp": issuedAt.Add(10 * time.Minute).Unix(),
+		"iss": fmt.Sprint(source.appId),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, source.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token requests a new installation access token.
+func (source *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := source.jwt()
+	if err != nil {
+		return nil, err
+	}
+	address := fmt.Sprintf("%s/app/installations/%d/access_tokens", source.apiURL, source.installationId)
+	req, err := http.NewRequest(http.MethodPost, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := source.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("not able to create an installation token of the Github App: %v body: %q",
+			resp.Status, body)
+	}
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	log.Debugf("Created an installation token of the Github App expiring at %s", token.ExpiresAt)
+	return &oauth2.Token{AccessToken: token.Token, TokenType: "Bearer", Expiry: token.ExpiresAt}, nil
+}
+
+// githubAPIURL returns the base URL of the Github REST API belonging to the
+// configured GraphQL endpoint.
+func githubAPIURL() string {
+	if config.Github.Endpoint == "" {
+		return "https://api.github.com"
+	}
+	// Github Enterprise Server serves GraphQL at /api/graphql and REST at /api/v3
+	return strings.TrimSuffix(config.Github.Endpoint, "/graphql") + "/v3"
+}
+
+// githubTokens is the token source of all Github requests. It is created once,
+// so that installation tokens of a Github App are reused until they expire.
+var githubTokens oauth2.TokenSource
+
+// githubTokenSource returns the token source for the configured authentication. A Github App is used if one is
+// configured, otherwise the token in $GITHUB_TOKEN.
+func githubTokenSource() (oauth2.TokenSource, error) {
+	if githubTokens != nil {
+		return githubTokens, nil
+	}
+
+	app := config.Github
+	if app.AppId == 0 && app.InstallationId == 0 && app.PrivateKeyFile == "" {
+		token := os.Getenv("GITHUB_TOKEN")
+		if token == "" {
+			return nil, errors.New("no Github authentication configured, please export $GITHUB_TOKEN " +
+				"or configure a Github App with appId, installationId and privateKeyFile in the [github] section")
+		}
+		githubTokens = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
+		return githubTokens, nil
+	}
+
+	if app.AppId == 0 || app.InstallationId == 0 || app.PrivateKeyFile == "" {
+		return nil, errors.New("the Github App needs appId, installationId and privateKeyFile in the [github] section")
+	}
+	data, err := ioutil.ReadFile(app.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("not able to read the private key of the Github App: %s", err)
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("not able to parse the private key of the Github App: %s", err)
+	}
+	transport, err := githubTransport()
+	if err != nil {
+		return nil, err
+	}
+	githubTokens = oauth2.ReuseTokenSource(nil, &appTokenSource{
+		appId:          app.AppId,
+		installationId: app.InstallationId,
+		key:            key,
+		apiURL:         githubAPIURL(),
+		client:         &http.Client{Transport: transport, Timeout: time.Minute},
+	})
+	return githubTokens, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGithubAppTokenSource(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile, err := ioutil.TempFile("", "filtra-app-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(keyFile.Name())
+	pem.Encode(keyFile, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	keyFile.Close()
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/app/installations/42/access_tokens" {
+			t.Errorf("Unexpected request: %s %s", r.Method, r.URL)
+		}
+		// The JSON Web Token needs to be signed with the private key of the app
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			t.Fatalf("Expected a JSON Web Token, but got %q", r.Header.Get("Authorization"))
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+			t.Errorf("Invalid signature of the JSON Web Token: %s", err)
+		}
+		if claims, _ := base64.RawURLEncoding.DecodeString(parts[1]); !strings.Contains(string(claims), `"iss":"7"`) {
+			t.Errorf("Expected the app as issuer of the JSON Web Token, but got %s", claims)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"token": "installation-token", "expires_at": "` +
+			time.Now().Add(time.Hour).Format(time.RFC3339) + `"}`))
+	}))
+	defer server.Close()
+
+	config.Github.Endpoint = server.URL + "/api/graphql"
+	config.Github.AppId = 7
+	config.Github.InstallationId = 42
+	config.Github.PrivateKeyFile = keyFile.Name()
+
+	src, err := githubTokenSource()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		token, err := src.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.AccessToken != "installation-token" {
+			t.Errorf("Expected the installation token, but got %q", token.AccessToken)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("Expected the installation token to be reused, but got %d requests", requests)
+	}
+}
+
+func TestGithubTokenSourceWithoutAuthentication(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+	defer os.Setenv("GITHUB_TOKEN", os.Getenv("GITHUB_TOKEN"))
+	os.Setenv("GITHUB_TOKEN", "")
+
+	if _, err := githubTokenSource(); err == nil || !strings.Contains(err.Error(), "GITHUB_TOKEN") {
+		t.Errorf("Expected an error about the missing authentication, but got %v", err)
+	}
+
+	config.Github.AppId = 7
+	if _, err := githubTokenSource(); err == nil || !strings.Contains(err.Error(), "installationId") {
+		t.Errorf("Expected an error about the incomplete Github App, but got %v", err)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// The columns of the lists every GitLab issue board has in addition to the label lists
+const (
+	gitlabOpenColumn   = "Open"
+	gitlabClosedColumn = "Closed"
+)
+
+// gitlabSource fetches the issues of projects on GitLab. GitLab issue boards are driven by labels, so the column
+// of an issue is the label of a board list it has and its history is read from the label events.
+type gitlabSource struct{}
+
+type gitlabIssue struct {
+	Iid       int       `json:"iid"`
+	Title     string    `json:"title"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+	ClosedAt  time.Time `json:"closed_at"`
+	Labels    []string  `json:"labels"`
+	WebURL    string    `json:"web_url"`
+}
+
+type gitlabBoard struct {
+	Name  string `json:"name"`
+	Lists []struct {
+		Label *struct {
+			Name string `json:"name"`
+		} `json:"label"`
+	} `json:"lists"`
+}
+
+type gitlabLabelEvent struct {
+	CreatedAt time.Time `json:"created_at"`
+	// Action is either "add" or "remove"
+	Action string `json:"action"`
+	// Label is nil if the label was deleted since
+	Label *struct {
+		Name string `json:"name"`
+	} `json:"label"`
+}
+
+// gitlabProject requests the API of a single GitLab project.
+type gitlabProject struct {
+	apiURL string
+	header http.Header
+}
+
+// getAll requests all pages of the given path of the project API and appends
+// the items of every page to the slice v points to.
+func (project gitlabProject) getAll(path string, v interface{}, appendPage func()) error {
+	for page := "1"; page != ""; {
+		respHeader, err := getJSON(fmt.Sprintf("%s%s?per_page=100&page=%s", project.apiURL, path, page),
+			project.header, v)
+		if err != nil {
+			return err
+		}
+		appendPage()
+		page = respHeader.Get("X-Next-Page")
+	}
+	return nil
+}
+
+func (gitlabSource) fetch(repos []repository) ([]*WorkItems, error) {
+	results := []*WorkItems{}
+	for _, repo := range repos {
+		workItems, err := fetchGitlabProject(repo)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		results = append(results, workItems)
+	}
+	return results, nil
+}
+
+// fetchGitlabProject fetches the issues of a GitLab project with their history on the boards of the project that
+// are configured.
+func fetchGitlabProject(repo repository) (*WorkItems, error) {
+	project := gitlabProject{
+		apiURL: repo.baseURL("https://gitlab.com") + "/api/v4/projects/" + url.PathEscape(repo.fullName()),
+		header: http.Header{"Private-Token": []string{os.Getenv("GITLAB_TOKEN")}},
+	}
+
+	// The label lists of the configured boards
+	boardLists := map[string][]string{}
+	boards := []gitlabBoard{}
+	page := []gitlabBoard{}
+	if err := project.getAll("/boards", &page, func() { boards = append(boards, page...) }); err != nil {
+		return nil, err
+	}
+	for _, gitlabBoard := range boards {
+		for boardName := range config.Boards {
+			if strings.ToLower(boardName) != strings.ToLower(gitlabBoard.Name) {
+				continue
+			}
+			lists := []string{}
+			for _, list := range gitlabBoard.Lists {
+				if list.Label != nil {
+					lists = append(lists, list.Label.Name)
+				}
+			}
+			boardLists[boardName] = lists
+		}
+	}
+
+	issues := []gitlabIssue{}
+	issuePage := []gitlabIssue{}
+	if err := project.getAll("/issues", &issuePage, func() { issues = append(issues, issuePage...) }); err != nil {
+		return nil, err
+	}
+	log.Debugf("Fetched %d issues of %s from GitLab", len(issues), repo.fullName())
+
+	workItems := &WorkItems{Repository: repo.fullName(), Items: []workItem{}}
+	for _, issue := range issues {
+		labelEvents := []gitlabLabelEvent{}
+		if len(boardLists) > 0 {
+			eventPage := []gitlabLabelEvent{}
+			path := fmt.Sprintf("/issues/%d/resource_label_events", issue.Iid)
+			if err := project.getAll(path, &eventPage, func() { labelEvents = append(labelEvents, eventPage...) }); err != nil {
+				return nil, err
+			}
+		}
+		workItems.Items = append(workItems.Items, issue.workItem(boardLists, labelEvents))
+	}
+	return workItems, nil
+}
+
+// workItem converts the issue into a work item. Issues are on every board of
+// their project, in the Open list until they are moved to one of the label
+// lists and in the Closed list once they are closed.
+func (issue gitlabIssue) workItem(boardLists map[string][]string, labelEvents []gitlabLabelEvent) workItem {
+	item := workItem{
+		Url:       issue.WebURL,
+		Title:     issue.Title,
+		State:     stateOpen,
+		CreatedAt: issue.CreatedAt,
+		Labels:    issue.Labels,
+		Columns:   []boardColumn{},
+		Log:       []itemEvent{},
+	}
+	if issue.State == "closed" {
+		item.State = stateClosed
+		item.ClosedAt = issue.ClosedAt
+	}
+
+	// Labels moved between lists are added and removed at the same time
+	sort.SliceStable(labelEvents, func(i, j int) bool {
+		if labelEvents[i].CreatedAt.Equal(labelEvents[j].CreatedAt) {
+			return labelEvents[i].Action == "add" && labelEvents[j].Action != "add"
+		}
+		return labelEvents[i].CreatedAt.Before(labelEvents[j].CreatedAt)
+	})
+
+	for boardName, lists := range boardLists {
+		column := gitlabOpenColumn
+		events := []columnEvent{{Added: true, CreatedAt: issue.CreatedAt}}
+		for _, event := range labelEvents {
+			if event.Label == nil || !isColumnInColumnSlice(event.Label.Name, lists) {
+				continue
+			}
+			switch {
+			case event.Action == "add" && event.Label.Name != column:
+				events = append(events, columnEvent{PreviousColumn: column, Column: event.Label.Name, CreatedAt: event.CreatedAt})
+				column = event.Label.Name
+			case event.Action == "remove" && event.Label.Name == column:
+				events = append(events, columnEvent{PreviousColumn: column, Column: gitlabOpenColumn, CreatedAt: event.CreatedAt})
+				column = gitlabOpenColumn
+			}
+		}
+		if item.State == stateClosed {
+			events = append(events, columnEvent{PreviousColumn: column, Column: gitlabClosedColumn, CreatedAt: issue.ClosedAt})
+			column = gitlabClosedColumn
+		}
+
+		item.Columns = append(item.Columns, boardColumn{Board: boardName, Column: column})
+		item.addBoardEvents(boardName, events)
+	}
+
+	for _, event := range labelEvents {
+		if event.Label == nil {
+			continue
+		}
+		logEvent := itemEvent{Type: eventLabeled, CreatedAt: event.CreatedAt, Label: event.Label.Name}
+		if event.Action == "remove" {
+			logEvent.Type = eventUnlabeled
+		}
+		item.Log = append(item.Log, logEvent)
+	}
+	if item.State == stateClosed {
+		item.Log = append(item.Log, itemEvent{Type: eventClosed, CreatedAt: item.ClosedAt})
+	}
+	item.sortLog()
+	return item
+}
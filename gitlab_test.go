@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFetchGitlabProject(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	responses := map[string]string{
+		"/api/v4/projects/brejoc%2Ftest/boards": `[{"name": "Test", "lists": [
+			{"label": {"name": "Planned"}}, {"label": {"name": "In progress"}}]}]`,
+		"/api/v4/projects/brejoc%2Ftest/issues": `[
+			{"iid": 1, "title": "Closed", "state": "closed", "created_at": "2019-06-01T10:00:00.000Z",
+			 "closed_at": "2019-06-05T10:00:00.000Z", "labels": ["bug"], "web_url": "https://gitlab.com/brejoc/test/-/issues/1"},
+			{"iid": 2, "title": "Open", "state": "opened", "created_at": "2019-06-02T10:00:00.000Z",
+			 "closed_at": null, "labels": [], "web_url": "https://gitlab.com/brejoc/test/-/issues/2"}]`,
+		"/api/v4/projects/brejoc%2Ftest/issues/1/resource_label_events": `[
+			{"created_at": "2019-06-02T10:00:00.000Z", "action": "add", "label": {"name": "Planned"}},
+			{"created_at": "2019-06-03T10:00:00.000Z", "action": "remove", "label": {"name": "Planned"}},
+			{"created_at": "2019-06-03T10:00:00.000Z", "action": "add", "label": {"name": "In progress"}},
+			{"created_at": "2019-06-04T10:00:00.000Z", "action": "add", "label": {"name": "bug"}}]`,
+		"/api/v4/projects/brejoc%2Ftest/issues/2/resource_label_events": `[]`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != "secret" {
+			t.Errorf("Expected the token to be sent")
+		}
+		response, ok := responses[r.URL.EscapedPath()]
+		if !ok {
+			t.Errorf("Unexpected request: %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	defer os.Setenv("GITLAB_TOKEN", os.Getenv("GITLAB_TOKEN"))
+	os.Setenv("GITLAB_TOKEN", "secret")
+
+	got, err := fetchGitlabProject(repository{Source: "gitlab", BaseURL: server.URL + "/", Owner: "brejoc", Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Repository != "brejoc/test" || len(got.Items) != 2 {
+		t.Fatalf("Expected 2 issues of brejoc/test, but got %d of %s", len(got.Items), got.Repository)
+	}
+
+	closed := got.Items[0]
+	if closed.State != stateClosed || !closed.ClosedAt.Equal(day(5)) {
+		t.Errorf("Expected issue 1 to be closed on %s, but got %s %s", day(5), closed.State, closed.ClosedAt)
+	}
+	wantEvents := []columnEvent{
+		{Added: true, CreatedAt: day(1)},
+		{PreviousColumn: "Open", Column: "Planned", CreatedAt: day(2)},
+		{PreviousColumn: "Planned", Column: "In progress", CreatedAt: day(3)},
+		{PreviousColumn: "In progress", Column: "Closed", CreatedAt: day(5)},
+	}
+	if !reflect.DeepEqual(closed.boardEvents("test"), wantEvents) {
+		t.Errorf("Got %v as events, but expected %v", closed.boardEvents("test"), wantEvents)
+	}
+	if !reflect.DeepEqual(closed.Columns, []boardColumn{{Board: "test", Column: "Closed"}}) {
+		t.Errorf("Expected issue 1 to be in the Closed list, but got %v", closed.Columns)
+	}
+
+	open := got.Items[1]
+	if open.State != stateOpen || !reflect.DeepEqual(open.Columns, []boardColumn{{Board: "test", Column: "Open"}}) {
+		t.Errorf("Expected issue 2 to be open in the Open list, but got %s %v", open.State, open.Columns)
+	}
+}
@@ -6,10 +6,10 @@ require (
 	github.com/BurntSushi/toml v0.3.1
 	github.com/brejoc/githubv4 v0.0.0-20190628081146-ff7a0d1c9aa4
 	github.com/brejoc/graphql v0.0.0-20190628081354-06d07f6a803c // indirect
-	github.com/golang/protobuf v1.3.1 // indirect
-	github.com/jasonlvhit/gocron v0.0.0-20191106203602-f82992d443f4 // indirect
 	github.com/lib/pq v1.3.0
+	github.com/prometheus/client_golang v1.0.0
 	github.com/sirupsen/logrus v1.4.2
+	github.com/stretchr/testify v1.4.0 // indirect
 	golang.org/x/net v0.0.0-20190628185345-da137c7871d7 // indirect
 	golang.org/x/oauth2 v0.0.0-20190604053449-0f29369cfe45
 )
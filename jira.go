@@ -0,0 +1,392 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// jiraTimeLayout is the layout of the times in the responses of the Jira REST API.
+const jiraTimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// jiraTime is a time in the layout of the Jira REST API.
+type jiraTime struct {
+	time.Time
+}
+
+func (t *jiraTime) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "null" || value == "" {
+		return nil
+	}
+	parsed, err := time.Parse(jiraTimeLayout, value)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed.UTC()
+	return nil
+}
+
+// jiraSource fetches the issues of projects on Jira. The columns of an issue are its statuses or, on boards that
+// reference a Jira board, the columns of the Jira board the statuses are mapped to.
+type jiraSource struct{}
+
+type jiraStatus struct {
+	Id             string `json:"id"`
+	Name           string `json:"name"`
+	StatusCategory struct {
+		Key string `json:"key"`
+	} `json:"statusCategory"`
+}
+
+type jiraHistory struct {
+	Created jiraTime `json:"created"`
+	Items   []struct {
+		Field      string `json:"field"`
+		From       string `json:"from"`
+		FromString string `json:"fromString"`
+		To         string `json:"to"`
+		ToString   string `json:"toString"`
+	} `json:"items"`
+}
+
+type jiraIssue struct {
+	Key    string `json:"key"`
+	Fields struct {
+		Summary        string     `json:"summary"`
+		Created        jiraTime   `json:"created"`
+		ResolutionDate jiraTime   `json:"resolutiondate"`
+		Labels         []string   `json:"labels"`
+		Status         jiraStatus `json:"status"`
+		// Resolution is nil for unresolved issues
+		Resolution *struct {
+			Name string `json:"name"`
+		} `json:"resolution"`
+	} `json:"fields"`
+	Changelog struct {
+		Total     int           `json:"total"`
+		Histories []jiraHistory `json:"histories"`
+	} `json:"changelog"`
+}
+
+// jiraSearchJqlPage is a page of the issue search of Jira Cloud.
+type jiraSearchJqlPage struct {
+	Issues        []jiraIssue `json:"issues"`
+	NextPageToken string      `json:"nextPageToken"`
+	IsLast        bool        `json:"isLast"`
+}
+
+// jiraSearchPage is a page of the issue searches of the Jira REST and Agile APIs.
+type jiraSearchPage struct {
+	StartAt    int         `json:"startAt"`
+	MaxResults int         `json:"maxResults"`
+	Total      int         `json:"total"`
+	Issues     []jiraIssue `json:"issues"`
+}
+
+type jiraChangelogPage struct {
+	StartAt    int           `json:"startAt"`
+	MaxResults int           `json:"maxResults"`
+	Total      int           `json:"total"`
+	Values     []jiraHistory `json:"values"`
+}
+
+type jiraBoardConfiguration struct {
+	ColumnConfig struct {
+		Columns []struct {
+			Name     string `json:"name"`
+			Statuses []struct {
+				Id string `json:"id"`
+			} `json:"statuses"`
+		} `json:"columns"`
+	} `json:"columnConfig"`
+}
+
+// jiraInstance requests the API of a Jira instance.
+type jiraInstance struct {
+	baseURL string
+	header  http.Header
+	// cloud is set for Jira Cloud, otherwise it is Jira Server or Data Center
+	cloud bool
+}
+
+// newJiraInstance returns the Jira instance of the repository. With $JIRA_USER
+// the token in $JIRA_TOKEN is used as API token of the user, otherwise as
+// personal access token.
+func newJiraInstance(repo repository) jiraInstance {
+	authorization := "Bearer " + os.Getenv("JIRA_TOKEN")
+	if user := os.Getenv("JIRA_USER"); user != "" {
+		authorization = "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+os.Getenv("JIRA_TOKEN")))
+	}
+	return jiraInstance{
+		baseURL: repo.baseURL(""),
+		header:  http.Header{"Authorization": []string{authorization}},
+	}
+}
+
+// isCloud reports whether the instance is Jira Cloud according to its server info.
+func (jira jiraInstance) isCloud() (bool, error) {
+	var serverInfo struct {
+		DeploymentType string `json:"deploymentType"`
+	}
+	if _, err := getJSON(jira.baseURL+"/rest/api/2/serverInfo", jira.header, &serverInfo); err != nil {
+		return false, err
+	}
+	return strings.ToLower(serverInfo.DeploymentType) == "cloud", nil
+}
+
+// searchIssues returns all issues found with the JQL query of the given query
+// parameters. Jira Cloud only offers the search that pages with tokens, Jira
+// Server and Data Center only the search that pages with offsets.
+func (jira jiraInstance) searchIssues(params url.Values) ([]jiraIssue, error) {
+	if !jira.cloud {
+		return jira.search("/rest/api/2/search", params)
+	}
+	issues := []jiraIssue{}
+	for {
+		page := jiraSearchJqlPage{}
+		if _, err := getJSON(jira.baseURL+"/rest/api/3/search/jql?"+params.Encode(), jira.header, &page); err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+		if page.IsLast || page.NextPageToken == "" {
+			return issues, nil
+		}
+		params.Set("nextPageToken", page.NextPageToken)
+	}
+}
+
+// search returns all issues found on the given path with the given query parameters.
+func (jira jiraInstance) search(path string, params url.Values) ([]jiraIssue, error) {
+	issues := []jiraIssue{}
+	for startAt := 0; ; {
+		params.Set("startAt", fmt.Sprint(startAt))
+		page := jiraSearchPage{}
+		if _, err := getJSON(jira.baseURL+path+"?"+params.Encode(), jira.header, &page); err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+		startAt = page.StartAt + len(page.Issues)
+		if len(page.Issues) == 0 || startAt >= page.Total {
+			return issues, nil
+		}
+	}
+}
+
+// histories returns the whole changelog of the issue. Searches only return the
+// first histories of the changelog, so the rest is requested if needed.
+func (jira jiraInstance) histories(issue jiraIssue) ([]jiraHistory, error) {
+	histories := issue.Changelog.Histories
+	for startAt := len(histories); startAt < issue.Changelog.Total; {
+		page := jiraChangelogPage{}
+		address := fmt.Sprintf("%s/rest/api/2/issue/%s/changelog?startAt=%d", jira.baseURL, issue.Key, startAt)
+		if _, err := getJSON(address, jira.header, &page); err != nil {
+			return nil, err
+		}
+		if len(page.Values) == 0 {
+			break
+		}
+		histories = append(histories, page.Values...)
+		startAt += len(page.Values)
+	}
+	return histories, nil
+}
+
+// jiraBoardIssues are the issues on a board and the columns of their statuses.
+type jiraBoardIssues struct {
+	keys map[string]bool
+	// columns holds the column names by status ID. Without columns the
+	// status names are the columns.
+	columns map[string]string
+}
+
+// column returns the column of the given status on the board.
+func (boardIssues jiraBoardIssues) column(statusId, statusName string) string {
+	if column, ok := boardIssues.columns[statusId]; ok {
+		return column
+	}
+	return statusName
+}
+
+func (jiraSource) fetch(repos []repository) ([]*WorkItems, error) {
+	results := []*WorkItems{}
+	for _, repo := range repos {
+		workItems, err := fetchJiraProject(repo)
+		if err != nil {
+			log.Error(err)
+			return nil, err
+		}
+		results = append(results, workItems)
+	}
+	return results, nil
+}
+
+// fetchJiraProject fetches the issues of the Jira project with the key given as
+// name of the repository, along with the issues of the configured boards that
+// reference a Jira board or a JQL query.
+func fetchJiraProject(repo repository) (*WorkItems, error) {
+	jira := newJiraInstance(repo)
+	cloud, err := jira.isCloud()
+	if err != nil {
+		return nil, err
+	}
+	jira.cloud = cloud
+	projectJql := fmt.Sprintf("project = %q", repo.Name)
+
+	boards := map[string]jiraBoardIssues{}
+	for boardName, b := range config.Boards {
+		if !b.isJira() {
+			continue
+		}
+		boardIssues := jiraBoardIssues{keys: map[string]bool{}, columns: map[string]string{}}
+		params := url.Values{"fields": []string{"key"}}
+		var issues []jiraIssue
+		var err error
+		if b.JiraBoard != 0 {
+			boardConfig := jiraBoardConfiguration{}
+			address := fmt.Sprintf("%s/rest/agile/1.0/board/%d/configuration", jira.baseURL, b.JiraBoard)
+			if _, err := getJSON(address, jira.header, &boardConfig); err != nil {
+				return nil, err
+			}
+			for _, column := range boardConfig.ColumnConfig.Columns {
+				for _, status := range column.Statuses {
+					boardIssues.columns[status.Id] = column.Name
+				}
+			}
+			params.Set("jql", projectJql)
+			issues, err = jira.search(fmt.Sprintf("/rest/agile/1.0/board/%d/issue", b.JiraBoard), params)
+		} else {
+			params.Set("jql", fmt.Sprintf("(%s) AND %s", b.Jql, projectJql))
+			issues, err = jira.searchIssues(params)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range issues {
+			boardIssues.keys[issue.Key] = true
+		}
+		boards[boardName] = boardIssues
+	}
+
+	issues, err := jira.searchIssues(url.Values{
+		"jql":    []string{projectJql + " ORDER BY created ASC"},
+		"fields": []string{"summary,created,resolutiondate,resolution,labels,status"},
+		"expand": []string{"changelog"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Fetched %d issues of %s from Jira", len(issues), repo.fullName())
+
+	workItems := &WorkItems{Repository: repo.fullName(), Items: []workItem{}}
+	for _, issue := range issues {
+		histories, err := jira.histories(issue)
+		if err != nil {
+			return nil, err
+		}
+		workItems.Items = append(workItems.Items, issue.workItem(jira.baseURL, boards, histories))
+	}
+	return workItems, nil
+}
+
+// workItem converts the issue into a work item. Issues are closed once their status is in the done category. The
+// status transitions of the changelog are the moves between the columns on the boards the issue is on.
+func (issue jiraIssue) workItem(baseURL string, boards map[string]jiraBoardIssues, histories []jiraHistory) workItem {
+	item := workItem{
+		Url:       baseURL + "/browse/" + issue.Key,
+		Title:     issue.Fields.Summary,
+		State:     stateOpen,
+		CreatedAt: issue.Fields.Created.Time,
+		Labels:    issue.Fields.Labels,
+		Columns:   []boardColumn{},
+		Log:       []itemEvent{},
+	}
+	if item.Labels == nil {
+		item.Labels = []string{}
+	}
+
+	sort.SliceStable(histories, func(i, j int) bool {
+		return histories[i].Created.Before(histories[j].Created.Time)
+	})
+
+	if issue.Fields.Status.StatusCategory.Key == "done" {
+		item.State = stateClosed
+		item.ClosedAt = issue.Fields.ResolutionDate.Time
+		if issue.Fields.Resolution != nil {
+			item.StateReason = issue.Fields.Resolution.Name
+		}
+		// Issues without resolution are closed with their last transition
+		if item.ClosedAt.IsZero() {
+			item.ClosedAt = item.CreatedAt
+			for _, history := range histories {
+				for _, change := range history.Items {
+					if change.Field == "status" {
+						item.ClosedAt = history.Created.Time
+					}
+				}
+			}
+		}
+	}
+
+	for boardName, boardIssues := range boards {
+		if !boardIssues.keys[issue.Key] {
+			continue
+		}
+		events := []columnEvent{{Added: true, CreatedAt: item.CreatedAt}}
+		for _, history := range histories {
+			for _, change := range history.Items {
+				if change.Field != "status" {
+					continue
+				}
+				previousColumn := boardIssues.column(change.From, change.FromString)
+				column := boardIssues.column(change.To, change.ToString)
+				// Statuses within the same column are no moves on the board
+				if previousColumn != column {
+					events = append(events, columnEvent{
+						PreviousColumn: previousColumn,
+						Column:         column,
+						CreatedAt:      history.Created.Time,
+					})
+				}
+			}
+		}
+		item.Columns = append(item.Columns, boardColumn{
+			Board:  boardName,
+			Column: boardIssues.column(issue.Fields.Status.Id, issue.Fields.Status.Name),
+		})
+		item.addBoardEvents(boardName, events)
+	}
+
+	for _, history := range histories {
+		for _, change := range history.Items {
+			if change.Field != "labels" {
+				continue
+			}
+			// Labels are changed as a whole, separated by spaces
+			from := strings.Fields(change.FromString)
+			to := strings.Fields(change.ToString)
+			for _, label := range to {
+				if !isColumnInColumnSlice(label, from) {
+					item.Log = append(item.Log, itemEvent{Type: eventLabeled, CreatedAt: history.Created.Time, Label: label})
+				}
+			}
+			for _, label := range from {
+				if !isColumnInColumnSlice(label, to) {
+					item.Log = append(item.Log, itemEvent{Type: eventUnlabeled, CreatedAt: history.Created.Time, Label: label})
+				}
+			}
+		}
+	}
+	if item.State == stateClosed {
+		item.Log = append(item.Log, itemEvent{Type: eventClosed, CreatedAt: item.ClosedAt})
+	}
+	item.sortLog()
+	return item
+}
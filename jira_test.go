@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFetchJiraProject(t *testing.T) {
+	// Jira Cloud only offers the search paging with tokens, Jira Server and Data Center the one paging with offsets
+	for _, deploymentType := range []string{"Server", "Cloud"} {
+		t.Run(deploymentType, func(t *testing.T) {
+			testFetchJiraProject(t, deploymentType)
+		})
+	}
+}
+
+func testFetchJiraProject(t *testing.T, deploymentType string) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+	config.Boards["jira"] = board{JiraBoard: 7, PlannedColumns: []string{"To Do"}}
+	config.Boards["jql"] = board{Jql: "labels = frontend"}
+
+	// Serves the responses recorded from Jira
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Basic YnJlam9jOnNlY3JldA==" {
+			t.Errorf("Expected the API token of the user to be sent")
+		}
+		recording := ""
+		switch {
+		case r.URL.Path == "/rest/api/2/serverInfo":
+			fmt.Fprintf(w, `{"deploymentType": %q}`, deploymentType)
+			return
+		case r.URL.Path == "/rest/agile/1.0/board/7/configuration":
+			recording = "board_configuration.json"
+		case r.URL.Path == "/rest/agile/1.0/board/7/issue":
+			recording = "board_issues.json"
+		case r.URL.Path == "/rest/api/2/search" && deploymentType == "Server":
+			recording = "search.json"
+			if r.URL.Query().Get("jql") == `(labels = frontend) AND project = "PROJ"` {
+				recording = "jql_issues.json"
+			}
+		case r.URL.Path == "/rest/api/3/search/jql" && deploymentType == "Cloud":
+			recording = "search_jql.json"
+			if r.URL.Query().Get("nextPageToken") == "CAEaAggC" {
+				recording = "search_jql_2.json"
+			} else if r.URL.Query().Get("jql") == `(labels = frontend) AND project = "PROJ"` {
+				recording = "jql_issues_cloud.json"
+			}
+		case r.URL.Path == "/rest/api/2/issue/PROJ-2/changelog":
+			recording = "changelog.json"
+		default:
+			t.Errorf("Unexpected request: %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response, err := ioutil.ReadFile("./test-data/jira/" + recording)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	defer os.Setenv("JIRA_USER", os.Getenv("JIRA_USER"))
+	defer os.Setenv("JIRA_TOKEN", os.Getenv("JIRA_TOKEN"))
+	os.Setenv("JIRA_USER", "brejoc")
+	os.Setenv("JIRA_TOKEN", "secret")
+
+	got, err := fetchJiraProject(repository{Source: "jira", BaseURL: server.URL, Owner: "example", Name: "PROJ"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Repository != "example/PROJ" || len(got.Items) != 2 {
+		t.Fatalf("Expected 2 issues of example/PROJ, but got %d of %s", len(got.Items), got.Repository)
+	}
+
+	closed := got.Items[0]
+	if closed.Url != server.URL+"/browse/PROJ-1" || closed.State != stateClosed || !closed.ClosedAt.Equal(day(5)) {
+		t.Errorf("Expected PROJ-1 to be closed on %s, but got %s %s", day(5), closed.State, closed.ClosedAt)
+	}
+	if closed.StateReason != "Done" {
+		t.Errorf("Expected the resolution Done as state reason of PROJ-1, but got %q", closed.StateReason)
+	}
+	// Moves between statuses of the same column are dropped
+	wantEvents := []columnEvent{
+		{Added: true, CreatedAt: day(1)},
+		{PreviousColumn: "To Do", Column: "In Progress", CreatedAt: day(2)},
+		{PreviousColumn: "In Progress", Column: "Done", CreatedAt: day(5)},
+	}
+	if !reflect.DeepEqual(closed.boardEvents("jira"), wantEvents) {
+		t.Errorf("Got %v as events, but expected %v", closed.boardEvents("jira"), wantEvents)
+	}
+	if !reflect.DeepEqual(closed.Columns, []boardColumn{{Board: "jira", Column: "Done"}}) {
+		t.Errorf("Expected PROJ-1 to be done on the Jira board only, but got %v", closed.Columns)
+	}
+
+	// The status change of the second page of the changelog is found, on JQL boards statuses are columns
+	open := got.Items[1]
+	if open.State != stateOpen || len(open.Columns) != 2 {
+		t.Fatalf("Expected PROJ-2 to be open on 2 boards, but got %s %v", open.State, open.Columns)
+	}
+	wantEvents = []columnEvent{
+		{Added: true, CreatedAt: day(2)},
+		{PreviousColumn: "Open", Column: "In Progress", CreatedAt: day(4)},
+	}
+	if !reflect.DeepEqual(open.boardEvents("jql"), wantEvents) {
+		t.Errorf("Got %v as events, but expected %v", open.boardEvents("jql"), wantEvents)
+	}
+	if got, _ := calculateCycleStart(open.boardEvents("jira"), open.CreatedAt, day(10), "jira"); !got.Equal(day(2)) {
+		t.Errorf("Expected the cycle time of PROJ-2 to start on %s, but got %s", day(2), got)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
 
@@ -12,11 +13,20 @@ import (
 
 // GithubMetrics stores all of the metrics gathered from graphql.
 type GithubMetrics struct {
+	Repo  map[string]*RepoMetrics
+	Board map[string]*BoardMetrics
+}
+
+// RepoMetrics stores the metrics of a single repository.
+type RepoMetrics struct {
 	closedIssueCounter int
 	openIssueCounter   int
 	openBugsCounter    int
 	openL3Counter      int
-	Board              map[string]*BoardMetrics
+	// excludedIssueCounter is the number of closed issues left out of the
+	// flow metrics and the throughput by the exclusion rules
+	excludedIssueCounter int
+	throughput           Throughput
 }
 
 // BoardMetrics stores the metrics of a particular board inside a repository.
@@ -27,8 +37,67 @@ type BoardMetrics struct {
 	openL3Counter       int
 	blockedIssueCounter int
 	plannedIssueCounter int
-	averageLeadTime     float64
-	averageCycleTime    float64
+	// excludedIssueCounter is the number of closed issues left out by the exclusion rules
+	excludedIssueCounter int
+	// incompleteIssueCounter is the number of closed issues left out as they
+	// were never moved to the start or end columns
+	incompleteIssueCounter int
+	throughput             Throughput
+	cumulativeFlow         CumulativeFlow
+	// FlowMetrics of all closed issues on the board
+	FlowMetrics
+	// windows holds the flow metrics of the issues closed within the
+	// last days by the size of the window in days
+	windows map[int]FlowMetrics
+	// issueBlockedTime holds the blocked time in days of every closed issue by URL
+	issueBlockedTime map[string]float64
+	// agingIssues holds the open issues that are in progress by URL
+	agingIssues map[string]agingIssue
+	// columnAgePercentiles holds the ages in days of the open issues by column and percentile
+	columnAgePercentiles map[string]map[int]float64
+	// averageColumnTime and columnTimePercentiles hold the times in days the
+	// closed issues spent in a column by column and by column and percentile.
+	// Only the issues that were in a column are taken into account for it.
+	averageColumnTime     map[string]float64
+	columnTimePercentiles map[string]map[int]float64
+	// overdueIssueCounter is the number of open issues older than the 85th
+	// percentile of the cycle time of all closed issues
+	overdueIssueCounter int
+}
+
+// agingIssue is an open issue on a board and how long it has been in progress.
+type agingIssue struct {
+	column string
+	// age is the time in days since the cycle time of the issue started
+	age     float64
+	overdue bool
+}
+
+// FlowMetrics stores the lead and cycle time related metrics of closed issues.
+type FlowMetrics struct {
+	averageLeadTime    float64
+	averageCycleTime   float64
+	averageBlockedTime float64
+	averageWipTime     float64
+	// leadTimePercentiles and cycleTimePercentiles hold the times in days by percentile
+	leadTimePercentiles  map[int]float64
+	cycleTimePercentiles map[int]float64
+	// flowEfficiency is the share of the cycle time issues were actively worked on
+	flowEfficiency float64
+	// reopenRate is the share of the issues that were reopened at least once
+	reopenRate float64
+}
+
+// issueFlow holds the flow times of a single closed issue on a board.
+type issueFlow struct {
+	closedAt    time.Time
+	leadTime    time.Duration
+	cycleTime   time.Duration
+	blockedTime time.Duration
+	wipTime     time.Duration
+	reopened    bool
+	// columnTimes holds the time spent in the columns of the board by column
+	columnTimes map[string]time.Duration
 }
 
 type dbWriter interface {
@@ -55,14 +124,17 @@ func (metrics GithubMetrics) writeToDB(db *sql.DB) {
 		}
 	}
 
-	// Totals for the repo
-	repoIssueMap := map[string]interface{}{
-		"OPEN":        metrics.openIssueCounter,
-		"CLOSED":      metrics.closedIssueCounter,
-		"OPEN_BUG":    metrics.openBugsCounter,
-		"OPEN_L3_BUG": metrics.openL3Counter,
+	// Totals for the repos
+	for repoName, repoMetrics := range metrics.Repo {
+		repoIssueMap := map[string]interface{}{
+			"OPEN":        repoMetrics.openIssueCounter,
+			"CLOSED":      repoMetrics.closedIssueCounter,
+			"OPEN_BUG":    repoMetrics.openBugsCounter,
+			"OPEN_L3_BUG": repoMetrics.openL3Counter,
+			"EXCLUDED":    repoMetrics.excludedIssueCounter,
+		}
+		mapToDb("insert into repo_counter(ts, type, value, repo) values ($1, $2, $3, $4)", repoIssueMap, repoName)
 	}
-	mapToDb("insert into repo_counter(ts, type, value) values ($1, $2, $3)", repoIssueMap)
 
 	// Board issue counters
 	for boardName, boardMetrics := range metrics.Board {
@@ -73,67 +145,234 @@ func (metrics GithubMetrics) writeToDB(db *sql.DB) {
 			"PLANNED":     boardMetrics.plannedIssueCounter,
 			"OPEN_BUG":    boardMetrics.openBugsCounter,
 			"OPEN_L3_BUG": boardMetrics.openL3Counter,
+			"OVERDUE":     boardMetrics.overdueIssueCounter,
+			"EXCLUDED":    boardMetrics.excludedIssueCounter,
+			"INCOMPLETE":  boardMetrics.incompleteIssueCounter,
 		}
 		mapToDb("insert into board_counter(ts, type, value, board) values ($1, $2, $3, $4)", boardIssueMap, boardName)
 	}
 
-	// Board issue counters
+	// Board flow metrics of all closed issues and of the configured windows
 	for boardName, boardMetrics := range metrics.Board {
-		boardFlowMap := map[string]interface{}{
-			"LEAD_TIME":  boardMetrics.averageLeadTime,
-			"CYCLE_TIME": boardMetrics.averageCycleTime,
+		mapToDb("insert into board_flow(ts, type, value, board, window_days) values ($1, $2, $3, $4, $5)",
+			boardMetrics.FlowMetrics.toMap(), boardName, 0)
+		for days, windowMetrics := range boardMetrics.windows {
+			mapToDb("insert into board_flow(ts, type, value, board, window_days) values ($1, $2, $3, $4, $5)",
+				windowMetrics.toMap(), boardName, days)
+		}
+	}
+
+	// Throughput of the repos and boards. Former values are updated so that
+	// the whole history is backfilled.
+	repoThroughputQuery := "insert into repo_throughput(ts, period_start, value, repo, period) values ($1, $2, $3, $4, $5) " +
+		"on conflict (repo, period, period_start) do update set ts = excluded.ts, value = excluded.value"
+	for repoName, repoMetrics := range metrics.Repo {
+		mapToDb(repoThroughputQuery, repoMetrics.throughput.daySeries(timeNow), repoName, "DAY")
+		mapToDb(repoThroughputQuery, repoMetrics.throughput.weekSeries(timeNow), repoName, "WEEK")
+	}
+	boardThroughputQuery := "insert into board_throughput(ts, period_start, value, board, period) values ($1, $2, $3, $4, $5) " +
+		"on conflict (board, period, period_start) do update set ts = excluded.ts, value = excluded.value"
+	for boardName, boardMetrics := range metrics.Board {
+		mapToDb(boardThroughputQuery, boardMetrics.throughput.daySeries(timeNow), boardName, "DAY")
+		mapToDb(boardThroughputQuery, boardMetrics.throughput.weekSeries(timeNow), boardName, "WEEK")
+	}
+
+	// Number of issues per column and day for cumulative flow diagrams. The
+	// whole history is updated as well.
+	boardCfdQuery := "insert into board_cfd(ts, board_column, value, board, day) values ($1, $2, $3, $4, $5) " +
+		"on conflict (board, day, board_column) do update set ts = excluded.ts, value = excluded.value"
+	for boardName, boardMetrics := range metrics.Board {
+		for day, columns := range boardMetrics.cumulativeFlow.series(timeNow) {
+			mapToDb(boardCfdQuery, columns, boardName, day)
+		}
+	}
+
+	// Flow metrics of the single issues
+	for boardName, boardMetrics := range metrics.Board {
+		issueBlockedMap := map[string]interface{}{}
+		for url, blockedTime := range boardMetrics.issueBlockedTime {
+			issueBlockedMap[url] = blockedTime
+		}
+		mapToDb("insert into issue_flow(ts, issue, value, board, type) values ($1, $2, $3, $4, $5)",
+			issueBlockedMap, boardName, "BLOCKED_TIME")
+
+		issueAgeMap := map[string]interface{}{}
+		issueOverdueMap := map[string]interface{}{}
+		for url, issue := range boardMetrics.agingIssues {
+			issueAgeMap[url] = issue.age
+			if issue.overdue {
+				issueOverdueMap[url] = issue.age
+			}
+		}
+		mapToDb("insert into issue_flow(ts, issue, value, board, type) values ($1, $2, $3, $4, $5)",
+			issueAgeMap, boardName, "AGE")
+		mapToDb("insert into issue_flow(ts, issue, value, board, type) values ($1, $2, $3, $4, $5)",
+			issueOverdueMap, boardName, "OVERDUE_AGE")
+	}
+
+	// Age percentiles of the open issues per column
+	for boardName, boardMetrics := range metrics.Board {
+		for column, percentiles := range boardMetrics.columnAgePercentiles {
+			columnAgeMap := map[string]interface{}{}
+			for percentile, age := range percentiles {
+				columnAgeMap[fmt.Sprintf("AGE_P%d", percentile)] = age
+			}
+			mapToDb("insert into board_aging(ts, type, value, board, board_column) values ($1, $2, $3, $4, $5)",
+				columnAgeMap, boardName, column)
+		}
+	}
+
+	// Times the closed issues spent in each column
+	for boardName, boardMetrics := range metrics.Board {
+		for column, percentiles := range boardMetrics.columnTimePercentiles {
+			columnTimeMap := map[string]interface{}{"TIME": boardMetrics.averageColumnTime[column]}
+			for percentile, columnTime := range percentiles {
+				columnTimeMap[fmt.Sprintf("TIME_P%d", percentile)] = columnTime
+			}
+			mapToDb("insert into board_column_time(ts, type, value, board, board_column) values ($1, $2, $3, $4, $5)",
+				columnTimeMap, boardName, column)
 		}
-		mapToDb("insert into board_flow(ts, type, value, board) values ($1, $2, $3, $4)", boardFlowMap, boardName)
 	}
 	tx.Commit()
 }
 
-// NewMetrics returns a GithubMetrics struct.
-func NewMetrics(results *QueryPages) GithubMetrics {
-	type boardLeadCycle struct {
-		accLeadTime  time.Duration
-		accCycleTime time.Duration
+// toMap returns the flow metrics by their type as stored in the DB.
+func (flow FlowMetrics) toMap() map[string]interface{} {
+	flowMap := map[string]interface{}{
+		"LEAD_TIME":       flow.averageLeadTime,
+		"CYCLE_TIME":      flow.averageCycleTime,
+		"BLOCKED_TIME":    flow.averageBlockedTime,
+		"WIP_TIME":        flow.averageWipTime,
+		"FLOW_EFFICIENCY": flow.flowEfficiency,
+		"REOPEN_RATE":     flow.reopenRate,
+	}
+	for percentile, leadTime := range flow.leadTimePercentiles {
+		flowMap[fmt.Sprintf("LEAD_TIME_P%d", percentile)] = leadTime
+	}
+	for percentile, cycleTime := range flow.cycleTimePercentiles {
+		flowMap[fmt.Sprintf("CYCLE_TIME_P%d", percentile)] = cycleTime
 	}
+	return flowMap
+}
 
-	metrics := GithubMetrics{Board: map[string]*BoardMetrics{}}
-	boardList := []string{}
-	timeCalc := map[string]*boardLeadCycle{}
+// newFlowMetrics calculates the flow metrics of the given closed issues.
+func newFlowMetrics(issues []issueFlow) FlowMetrics {
+	var accLeadTime, accCycleTime, accBlockedTime, accWipTime time.Duration
+	leadTimes := []float64{}
+	cycleTimes := []float64{}
+	reopened := 0
+	for _, issue := range issues {
+		if issue.reopened {
+			reopened++
+		}
+		accLeadTime += issue.leadTime
+		accCycleTime += issue.cycleTime
+		accBlockedTime += issue.blockedTime
+		accWipTime += issue.wipTime
+		leadTimes = append(leadTimes, issue.leadTime.Hours()/24)
+		cycleTimes = append(cycleTimes, issue.cycleTime.Hours()/24)
+	}
+
+	// Calculate average lead, cycle, blocked and work in progress times, the flow efficiency and the reopen rate
+	flow := FlowMetrics{
+		averageLeadTime:      accLeadTime.Hours() / 24 / float64(len(issues)),
+		averageCycleTime:     accCycleTime.Hours() / 24 / float64(len(issues)),
+		averageBlockedTime:   accBlockedTime.Hours() / 24 / float64(len(issues)),
+		averageWipTime:       accWipTime.Hours() / 24 / float64(len(issues)),
+		flowEfficiency:       accWipTime.Hours() / accCycleTime.Hours(),
+		reopenRate:           float64(reopened) / float64(len(issues)),
+		leadTimePercentiles:  map[int]float64{},
+		cycleTimePercentiles: map[int]float64{},
+	}
+
+	// Calculate lead and cycle time percentiles
+	for _, percentile := range config.percentiles() {
+		flow.leadTimePercentiles[percentile] = calculatePercentile(leadTimes, percentile)
+		flow.cycleTimePercentiles[percentile] = calculatePercentile(cycleTimes, percentile)
+	}
+	return flow
+}
+
+// closedSince returns the issues that were closed at or after since.
+func closedSince(issues []issueFlow, since time.Time) []issueFlow {
+	closed := []issueFlow{}
+	for _, issue := range issues {
+		if !issue.closedAt.Before(since) {
+			closed = append(closed, issue)
+		}
+	}
+	return closed
+}
+
+// now returns the current time. It is replaced in tests.
+var now = time.Now
+
+// NewMetrics returns a GithubMetrics struct. Boards are aggregated across
+// all of the given repositories.
+func NewMetrics(results ...*WorkItems) GithubMetrics {
+	metrics := GithubMetrics{Repo: map[string]*RepoMetrics{}, Board: map[string]*BoardMetrics{}}
+	boardIssues := map[string][]issueFlow{}
+	currentTime := now()
 
 	for k := range config.Boards {
-		boardList = append(boardList, k)
-		timeCalc[k] = &boardLeadCycle{}
-		metrics.Board[k] = &BoardMetrics{}
+		boardIssues[k] = []issueFlow{}
+		metrics.Board[k] = &BoardMetrics{
+			throughput:       newThroughput(),
+			cumulativeFlow:   newCumulativeFlow(),
+			issueBlockedTime: map[string]float64{},
+			agingIssues:      map[string]agingIssue{},
+		}
 	}
 
-	for _, result := range results.Queries {
-		for _, issue := range result.Repository.Issues.Nodes {
+	for _, workItems := range results {
+		repo, ok := config.repository(workItems.Repository)
+		if !ok {
+			log.Warnf("Repository %s is not configured, skipping it", workItems.Repository)
+			continue
+		}
+		repoMetrics := &RepoMetrics{throughput: newThroughput()}
+		metrics.Repo[workItems.Repository] = repoMetrics
+
+		for _, item := range workItems.Items {
 
 			isBug := false
 			isL3 := false
+			// Closed issues like duplicates are only counted
+			excluded := repo.isExcluded(item)
+			// Reopened issues count as closed with their last or first close
+			closedAt := item.ClosedAt
+			if config.reopenedIssues() == reopenedFirstClose {
+				closedAt = item.firstClosedAt()
+			}
 
 			//  Repository Total Open and Closed issues
-			if issue.State == "CLOSED" {
-				metrics.closedIssueCounter++
-			} else if issue.State == "OPEN" {
-				metrics.openIssueCounter++
+			if item.State == stateClosed {
+				repoMetrics.closedIssueCounter++
+				if excluded {
+					repoMetrics.excludedIssueCounter++
+				} else {
+					repoMetrics.throughput.add(closedAt)
+				}
+			} else if item.State == stateOpen {
+				repoMetrics.openIssueCounter++
 
 				// Check labels
-				for _, label := range issue.Labels.Nodes {
-					labelName := strings.ToLower(string(label.Name))
+				for _, label := range item.Labels {
+					labelName := strings.ToLower(label)
 
 					// Is it a bug?
-					for _, bugLabel := range config.Repository.BugLabels {
+					for _, bugLabel := range repo.BugLabels {
 						if labelName == strings.ToLower(bugLabel) {
-							metrics.openBugsCounter++
+							repoMetrics.openBugsCounter++
 							isBug = true
 							break
 						}
 					}
 
 					// Is it a support issue?
-					for _, supportLabel := range config.Repository.SupportLabels {
+					for _, supportLabel := range repo.SupportLabels {
 						if labelName == strings.ToLower(supportLabel) {
-							metrics.openL3Counter++
+							repoMetrics.openL3Counter++
 							isL3 = true
 							break
 						}
@@ -142,34 +381,62 @@ func NewMetrics(results *QueryPages) GithubMetrics {
 			}
 
 			// Iterate over project boards
-			for _, column := range issue.ProjectCards.Nodes {
-				boardName := string(column.Column.Project.Name)
-				columnName := strings.ToLower(string(column.Column.Name))
+			for _, column := range item.Columns {
+				boardName := column.Board
+				columnName := strings.ToLower(column.Column)
+				events := item.boardEvents(boardName)
 
-				// Skip boards that are not part of the configured list
-				if !isColumnInColumnSlice(boardName, boardList) {
-					continue
-				}
+				// Reconstruct the history of the columns on the board
+				stints := calculateStintsWithRemovals(calculateColumnStints(events, item.CreatedAt, column.Column),
+					item.removals(boardName))
+				metrics.Board[boardName].cumulativeFlow.add(stints)
 
 				// Open / Closed issues inside board
-				if issue.State == "CLOSED" {
+				if item.State == stateClosed {
 					metrics.Board[boardName].closedIssueCounter++
+					if excluded {
+						metrics.Board[boardName].excludedIssueCounter++
+						log.Debugf("Issue %s is excluded from the flow metrics of board %s", item.Url, boardName)
+						continue
+					}
+					metrics.Board[boardName].throughput.add(closedAt)
 
-					// get and append lead time of issue
-					leadTime := calculateLeadTime(issue.CreatedAt, issue.ClosedAt)
-					timeCalc[boardName].accLeadTime += leadTime
-
-					// get and append cycle time of issue
-					cycleTime := calculateCycleTime(issue.TimelineItems, issue.CreatedAt, issue.ClosedAt, boardName)
-					timeCalc[boardName].accCycleTime += cycleTime
+					// get lead, cycle, blocked and work in progress times of issue
+					cycleTime, ok := calculateCycleTime(events, item.CreatedAt, closedAt, boardName)
+					if !ok {
+						metrics.Board[boardName].incompleteIssueCounter++
+						log.Debugf("Issue %s lacks the events for the cycle time on board %s", item.Url, boardName)
+						continue
+					}
+					leadTime := calculateLeadTime(item.CreatedAt, closedAt)
+					if config.reopenedIssues() == reopenedActiveTime {
+						// The cycle time still ends with the move to an end column
+						intervals := item.activeIntervals()
+						cycleEnd, _ := calculateCycleEnd(events, closedAt, boardName)
+						cycleStart, _ := calculateCycleStart(events, item.CreatedAt, cycleEnd, boardName)
+						leadTime = calculateActiveTime(intervals, item.CreatedAt, closedAt)
+						cycleTime = calculateActiveTime(intervals, cycleStart, cycleEnd)
+					}
+					blockedTime := calculateBlockedTime(stints, closedAt, boardName)
+					wipTime := calculateWipTime(stints, closedAt, boardName)
+					boardIssues[boardName] = append(boardIssues[boardName], issueFlow{
+						closedAt:    closedAt,
+						leadTime:    leadTime,
+						cycleTime:   cycleTime,
+						blockedTime: blockedTime,
+						wipTime:     wipTime,
+						reopened:    item.wasReopened(),
+						columnTimes: calculateTimePerColumn(stints, closedAt),
+					})
+					metrics.Board[boardName].issueBlockedTime[item.Url] = blockedTime.Hours() / 24
 
 					if log.IsLevelEnabled(log.DebugLevel) {
-						fmtOut, _ := json.MarshalIndent(issue.TimelineItems, "", "  ")
-						log.Debugf("Issue: %+v, Board: %s, Lead time: %v, Cycle time: %v, Created:%v, Closed: %v, timeline:%s\n",
-							issue.Url, boardName, leadTime, cycleTime, issue.CreatedAt, issue.ClosedAt, fmtOut)
+						fmtOut, _ := json.MarshalIndent(events, "", "  ")
+						log.Debugf("Issue: %+v, Board: %s, Lead time: %v, Cycle time: %v, Created:%v, Closed: %v, events:%s\n",
+							item.Url, boardName, leadTime, cycleTime, item.CreatedAt, closedAt, fmtOut)
 					}
 
-				} else if issue.State == "OPEN" {
+				} else if item.State == stateOpen {
 					metrics.Board[boardName].openIssueCounter++
 
 					// Open Bugs and L3s inside board
@@ -186,17 +453,92 @@ func NewMetrics(results *QueryPages) GithubMetrics {
 					} else if isColumnInColumnSlice(columnName, config.Boards[boardName].PlannedColumns) {
 						metrics.Board[boardName].plannedIssueCounter++
 					}
+
+					// Age of the issues in progress, that are neither planned, done nor in an end column, since the
+					// work on them started
+					b := config.Boards[boardName]
+					workStart, ok := calculateWorkStart(events, item.CreatedAt, currentTime, boardName)
+					if ok && !isColumnInColumnSlice(columnName, b.PlannedColumns) &&
+						!b.isDoneColumn(columnName) && !b.isEndColumn(columnName) {
+						metrics.Board[boardName].agingIssues[item.Url] = agingIssue{
+							column: column.Column,
+							age:    currentTime.Sub(workStart).Hours() / 24,
+						}
+					}
+				}
+			}
+
+			// Issues removed from a board still were in its columns until then
+			for boardName := range config.Boards {
+				removals := item.removals(boardName)
+				if len(removals) == 0 || item.isOnBoard(boardName) {
+					continue
 				}
+				stints := calculateColumnStints(item.boardEvents(boardName), item.CreatedAt, "")
+				metrics.Board[boardName].cumulativeFlow.add(calculateStintsWithRemovals(stints, removals))
+			}
+		}
+	}
+
+	// Calculate the flow metrics of all closed issues and of the configured windows
+	for boardName, boardMetrics := range metrics.Board {
+		boardMetrics.FlowMetrics = newFlowMetrics(boardIssues[boardName])
+		boardMetrics.windows = map[int]FlowMetrics{}
+		for _, days := range config.Boards[boardName].Windows {
+			boardMetrics.windows[days] = newFlowMetrics(closedSince(boardIssues[boardName], currentTime.AddDate(0, 0, -days)))
+		}
+	}
+
+	// Calculate the average times and the time percentiles of the closed issues per column
+	for boardName, boardMetrics := range metrics.Board {
+		columnTimes := map[string][]float64{}
+		for _, issue := range boardIssues[boardName] {
+			for column, columnTime := range issue.columnTimes {
+				columnTimes[column] = append(columnTimes[column], columnTime.Hours()/24)
+			}
+		}
+
+		boardMetrics.averageColumnTime = map[string]float64{}
+		boardMetrics.columnTimePercentiles = map[string]map[int]float64{}
+		for column, times := range columnTimes {
+			var accTime float64
+			for _, columnTime := range times {
+				accTime += columnTime
+			}
+			boardMetrics.averageColumnTime[column] = accTime / float64(len(times))
+			boardMetrics.columnTimePercentiles[column] = map[int]float64{}
+			for _, percentile := range config.percentiles() {
+				boardMetrics.columnTimePercentiles[column][percentile] = calculatePercentile(times, percentile)
 			}
 		}
 	}
 
-	// Calculate average lead and cycle times
+	// Flag the open issues older than the 85th percentile of the cycle time and
+	// calculate the age percentiles per column
 	for boardName, boardMetrics := range metrics.Board {
-		metrics.Board[boardName].averageLeadTime =
-			timeCalc[boardName].accLeadTime.Hours() / 24 / float64(boardMetrics.closedIssueCounter)
-		metrics.Board[boardName].averageCycleTime =
-			timeCalc[boardName].accCycleTime.Hours() / 24 / float64(boardMetrics.closedIssueCounter)
+		cycleTimes := []float64{}
+		for _, issue := range boardIssues[boardName] {
+			cycleTimes = append(cycleTimes, issue.cycleTime.Hours()/24)
+		}
+		cycleTimeP85 := calculatePercentile(cycleTimes, 85)
+
+		columnAges := map[string][]float64{}
+		for url, issue := range boardMetrics.agingIssues {
+			if issue.age > cycleTimeP85 {
+				issue.overdue = true
+				boardMetrics.agingIssues[url] = issue
+				boardMetrics.overdueIssueCounter++
+			}
+			columnAges[issue.column] = append(columnAges[issue.column], issue.age)
+		}
+
+		boardMetrics.columnAgePercentiles = map[string]map[int]float64{}
+		for column, ages := range columnAges {
+			boardMetrics.columnAgePercentiles[column] = map[int]float64{}
+			for _, percentile := range config.percentiles() {
+				boardMetrics.columnAgePercentiles[column][percentile] = calculatePercentile(ages, percentile)
+			}
+		}
 	}
 
 	return metrics
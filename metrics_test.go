@@ -1,10 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"github.com/brejoc/filtra/persist"
 	log "github.com/sirupsen/logrus"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestNewMetrics(t *testing.T) {
@@ -15,7 +17,9 @@ func TestNewMetrics(t *testing.T) {
 	if err := persist.Load("./test-data/query_pages.dump", &results); err != nil {
 		log.Fatal(err)
 	}
-	got := NewMetrics(&results)
+	now = func() time.Time { return time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC) }
+	defer func() { now = time.Now }()
+	got := NewMetrics(results.workItems())
 
 	testBoard := map[string]*BoardMetrics{"test": &BoardMetrics{
 		closedIssueCounter:  4,
@@ -24,21 +28,343 @@ func TestNewMetrics(t *testing.T) {
 		plannedIssueCounter: 3,
 		openBugsCounter:     1,
 		openL3Counter:       1,
-		averageLeadTime:     165.7965451388889,
-		averageCycleTime:    148.83974247685185,
+		// Issues 8 and 13 have no events on the board
+		incompleteIssueCounter: 2,
+		throughput: Throughput{
+			days:  map[string]int{"2019-06-26": 1, "2019-06-28": 2, "2019-08-24": 1},
+			weeks: map[string]int{"2019-06-24": 3, "2019-08-19": 1},
+		},
+		cumulativeFlow: CumulativeFlow{changes: map[string]map[string]int{
+			"2019-01-10": {"Done": 2, "In progress": 0},
+			"2019-06-26": {"Done": 1, "To do": 1},
+			"2019-06-28": {"Done": 0, "In progress": 1, "To do": 0},
+			"2019-08-24": {"Done": 1},
+			"2019-08-25": {"Blocked / Postponed": 2, "Planned": 3, "Waiting for Request": 1},
+		}},
+		FlowMetrics: FlowMetrics{
+			averageLeadTime:    202.61392939814814,
+			averageCycleTime:   168.70032407407408,
+			averageBlockedTime: 0,
+			averageWipTime:     0.009675925925925926,
+			leadTimePercentiles: map[int]float64{
+				50: 202.6138888888889,
+				85: 202.6139699074074,
+				95: 202.6139699074074,
+			},
+			cycleTimePercentiles: map[int]float64{
+				50: 168.70025462962963,
+				85: 168.70039351851852,
+				95: 168.70039351851852,
+			},
+			flowEfficiency: 5.735570443645002e-05,
+		},
+		windows: map[int]FlowMetrics{},
+		issueBlockedTime: map[string]float64{
+			"https://github.com/brejoc/test/issues/6": 0,
+			"https://github.com/brejoc/test/issues/7": 0,
+		},
+		agingIssues: map[string]agingIssue{
+			"https://github.com/brejoc/test/issues/11": {column: "In progress", age: 248.4616898148148, overdue: true},
+		},
+		columnAgePercentiles: map[string]map[int]float64{
+			"In progress": {50: 248.4616898148148, 85: 248.4616898148148, 95: 248.4616898148148},
+		},
+		averageColumnTime: map[string]float64{
+			"Done":        168.69064814814817,
+			"In progress": 0.019351851851851853,
+		},
+		columnTimePercentiles: map[string]map[int]float64{
+			"Done":        {50: 168.6809027777778, 85: 168.70039351851852, 95: 168.70039351851852},
+			"In progress": {50: 0.019351851851851853, 85: 0.019351851851851853, 95: 0.019351851851851853},
+		},
+		overdueIssueCounter: 1,
 	}}
 
-	want := GithubMetrics{
+	testRepo := map[string]*RepoMetrics{"brejoc/test": &RepoMetrics{
 		closedIssueCounter: 5,
 		openIssueCounter:   9,
 		openBugsCounter:    1,
 		openL3Counter:      2,
-		Board:              testBoard,
+		throughput: Throughput{
+			days:  map[string]int{"2017-09-06": 1, "2019-06-26": 1, "2019-06-28": 2, "2019-08-24": 1},
+			weeks: map[string]int{"2017-09-04": 1, "2019-06-24": 3, "2019-08-19": 1},
+		},
+	}}
+
+	want := GithubMetrics{
+		Repo:  testRepo,
+		Board: testBoard,
 	}
 
 	if !reflect.DeepEqual(want, got) {
-		t.Logf("Got this:    %v %v\n", got, got.Board["test"])
-		t.Logf("Wanted this: %v %v\n", want, want.Board["test"])
+		t.Logf("Got this:    %v %v %v\n", got, got.Repo["brejoc/test"], got.Board["test"])
+		t.Logf("Wanted this: %v %v %v\n", want, want.Repo["brejoc/test"], want.Board["test"])
 		t.Error("Metrics are not matching.")
 	}
 }
+
+func TestNewMetricsMultipleRepositories(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	var results, results2 QueryPages
+	if err := persist.Load("./test-data/query_pages.dump", &results); err != nil {
+		log.Fatal(err)
+	}
+	if err := persist.Load("./test-data/query_pages.dump", &results2); err != nil {
+		log.Fatal(err)
+	}
+	results2.Repository = "brejoc/test2"
+	got := NewMetrics(results.workItems(), results2.workItems())
+
+	if len(got.Repo) != 2 {
+		t.Fatalf("Expected metrics for 2 repositories, but got %d", len(got.Repo))
+	}
+	if got.Repo["brejoc/test2"].openL3Counter != 1 {
+		t.Errorf("Expected 1 open L3 issue in brejoc/test2, but got %d", got.Repo["brejoc/test2"].openL3Counter)
+	}
+	if got.Board["test"].closedIssueCounter != 8 {
+		t.Errorf("Expected 8 closed issues on the board, but got %d", got.Board["test"].closedIssueCounter)
+	}
+	if got.Board["test"].averageLeadTime != 202.61392939814814 {
+		t.Errorf("Expected an average lead time of 202.61392939814814, but got %v", got.Board["test"].averageLeadTime)
+	}
+}
+
+func TestClosedSince(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	now := time.Now()
+	issues := []issueFlow{
+		{closedAt: now.AddDate(0, 0, -100), leadTime: 40 * 24 * time.Hour, cycleTime: 20 * 24 * time.Hour},
+		{closedAt: now.AddDate(0, 0, -20), leadTime: 10 * 24 * time.Hour, cycleTime: 4 * 24 * time.Hour},
+		{closedAt: now.AddDate(0, 0, -5), leadTime: 6 * 24 * time.Hour, cycleTime: 2 * 24 * time.Hour},
+	}
+
+	window := newFlowMetrics(closedSince(issues, now.AddDate(0, 0, -30)))
+	if window.averageLeadTime != 8 {
+		t.Errorf("Expected an average lead time of 8 days in the window, but got %v", window.averageLeadTime)
+	}
+	if window.cycleTimePercentiles[95] != 4 {
+		t.Errorf("Expected a 95th percentile cycle time of 4 days in the window, but got %v", window.cycleTimePercentiles[95])
+	}
+
+	all := newFlowMetrics(issues)
+	if all.averageLeadTime != 56.0/3 {
+		t.Errorf("Expected an average lead time of %v days, but got %v", 56.0/3, all.averageLeadTime)
+	}
+}
+
+func TestNewMetricsReopenedIssues(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	reopened := workItem{
+		Url:       "https://github.com/brejoc/test/issues/1",
+		State:     stateClosed,
+		CreatedAt: day(1),
+		ClosedAt:  day(21),
+		Labels:    []string{},
+		Columns:   []boardColumn{{Board: "test", Column: "Done"}},
+		Log: []itemEvent{
+			{Type: eventAdded, CreatedAt: day(1), Board: "test"},
+			{Type: eventMoved, CreatedAt: day(2), Board: "test", PreviousColumn: "To do", Column: "Planned"},
+			{Type: eventMoved, CreatedAt: day(4), Board: "test", PreviousColumn: "Planned", Column: "Done"},
+			{Type: eventClosed, CreatedAt: day(5)},
+			{Type: eventReopened, CreatedAt: day(11)},
+			{Type: eventClosed, CreatedAt: day(21)},
+		},
+	}
+	closed := workItem{
+		Url:       "https://github.com/brejoc/test/issues/2",
+		State:     stateClosed,
+		CreatedAt: day(1),
+		ClosedAt:  day(3),
+		Labels:    []string{},
+		Columns:   []boardColumn{{Board: "test", Column: "Done"}},
+		Log: []itemEvent{
+			{Type: eventAdded, CreatedAt: day(1), Board: "test"},
+			{Type: eventClosed, CreatedAt: day(3)},
+		},
+	}
+	results := &WorkItems{Repository: "brejoc/test", Items: []workItem{reopened, closed}}
+
+	tests := []struct {
+		reopenedIssues string
+		endColumns     []string
+		leadTime       float64
+		cycleTime      float64
+		closedDay      string
+	}{
+		{"", nil, 11, 10.5, "2019-06-21"},
+		{"last", nil, 11, 10.5, "2019-06-21"},
+		{"first", nil, 3, 2.5, "2019-06-05"},
+		{"active", nil, 8, 7.5, "2019-06-21"},
+		// The active time of the cycle ends with the move to an end column
+		{"active", []string{"Done"}, 8, 2, "2019-06-21"},
+	}
+	for _, test := range tests {
+		config.ReopenedIssues = test.reopenedIssues
+		b := config.Boards["test"]
+		b.EndColumns = test.endColumns
+		config.Boards["test"] = b
+		got := NewMetrics(results)
+		flow := got.Board["test"].FlowMetrics
+		if flow.averageLeadTime != test.leadTime {
+			t.Errorf("Expected an average lead time of %v with %q, but got %v",
+				test.leadTime, test.reopenedIssues, flow.averageLeadTime)
+		}
+		if flow.averageCycleTime != test.cycleTime {
+			t.Errorf("Expected an average cycle time of %v with %q, but got %v",
+				test.cycleTime, test.reopenedIssues, flow.averageCycleTime)
+		}
+		if flow.reopenRate != 0.5 {
+			t.Errorf("Expected a reopen rate of 0.5 with %q, but got %v", test.reopenedIssues, flow.reopenRate)
+		}
+		// The throughput counts the same close as the flow metrics
+		for _, throughput := range []Throughput{got.Repo["brejoc/test"].throughput, got.Board["test"].throughput} {
+			if throughput.days[test.closedDay] != 1 || throughput.days["2019-06-03"] != 1 || len(throughput.days) != 2 {
+				t.Errorf("Expected the reopened issue to be closed on %s with %q, but got %v",
+					test.closedDay, test.reopenedIssues, throughput.days)
+			}
+		}
+	}
+}
+
+func TestNewMetricsExcludedIssues(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+	config.Repositories[0].ExcludeStateReasons = []string{"not_planned", "DUPLICATE"}
+	config.Repositories[0].ExcludeLabels = []string{"invalid"}
+
+	newItem := func(number int, state, stateReason string, labels ...string) workItem {
+		item := workItem{
+			Url:         fmt.Sprintf("https://github.com/brejoc/test/issues/%d", number),
+			State:       state,
+			CreatedAt:   day(1),
+			StateReason: stateReason,
+			Labels:      labels,
+			Columns:     []boardColumn{{Board: "test", Column: "Done"}},
+			Log:         []itemEvent{{Type: eventAdded, CreatedAt: day(1), Board: "test"}},
+		}
+		if state == stateClosed {
+			item.ClosedAt = day(1 + number)
+		}
+		return item
+	}
+	results := &WorkItems{Repository: "brejoc/test", Items: []workItem{
+		newItem(1, stateClosed, "COMPLETED"),
+		newItem(2, stateClosed, "NOT_PLANNED"),
+		newItem(3, stateClosed, "DUPLICATE"),
+		newItem(4, stateClosed, "COMPLETED", "Invalid"),
+		// Open issues are never excluded
+		newItem(5, stateOpen, "", "invalid"),
+	}}
+	got := NewMetrics(results)
+
+	repoMetrics := got.Repo["brejoc/test"]
+	if repoMetrics.closedIssueCounter != 4 || repoMetrics.excludedIssueCounter != 3 {
+		t.Errorf("Expected 4 closed and 3 excluded issues in the repository, but got %d and %d",
+			repoMetrics.closedIssueCounter, repoMetrics.excludedIssueCounter)
+	}
+	if repoMetrics.throughput.days["2019-06-02"] != 1 || len(repoMetrics.throughput.days) != 1 {
+		t.Errorf("Expected only the completed issue in the throughput, but got %v", repoMetrics.throughput.days)
+	}
+
+	boardMetrics := got.Board["test"]
+	if boardMetrics.closedIssueCounter != 4 || boardMetrics.excludedIssueCounter != 3 ||
+		boardMetrics.openIssueCounter != 1 {
+		t.Errorf("Expected 4 closed, 3 excluded and 1 open issue on the board, but got %d, %d and %d",
+			boardMetrics.closedIssueCounter, boardMetrics.excludedIssueCounter, boardMetrics.openIssueCounter)
+	}
+	if boardMetrics.averageLeadTime != 1 || len(boardMetrics.issueBlockedTime) != 1 {
+		t.Errorf("Expected only the completed issue in the flow metrics, but got a lead time of %v and %v",
+			boardMetrics.averageLeadTime, boardMetrics.issueBlockedTime)
+	}
+}
+
+func TestNewMetricsIncompleteIssues(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+	config.Boards["test"] = board{StartColumns: []string{"In progress"}, MissingEvents: "skip"}
+
+	started := workItem{
+		Url:       "https://github.com/brejoc/test/issues/1",
+		State:     stateClosed,
+		CreatedAt: day(1),
+		ClosedAt:  day(10),
+		Labels:    []string{},
+		Columns:   []boardColumn{{Board: "test", Column: "Done"}},
+		Log: []itemEvent{
+			{Type: eventAdded, CreatedAt: day(1), Board: "test"},
+			{Type: eventMoved, CreatedAt: day(4), Board: "test", PreviousColumn: "To do", Column: "In progress"},
+		},
+	}
+	// Closed and open issues that were never moved to a start column
+	closed := started
+	closed.Url = "https://github.com/brejoc/test/issues/2"
+	closed.Log = closed.Log[:1]
+	open := closed
+	open.Url = "https://github.com/brejoc/test/issues/3"
+	open.State = stateOpen
+	open.ClosedAt = time.Time{}
+	open.Columns = []boardColumn{{Board: "test", Column: "To do"}}
+
+	boardMetrics := NewMetrics(&WorkItems{Repository: "brejoc/test", Items: []workItem{started, closed, open}}).Board["test"]
+	if boardMetrics.closedIssueCounter != 2 || boardMetrics.incompleteIssueCounter != 1 {
+		t.Errorf("Expected 2 closed and 1 incomplete issue, but got %d and %d",
+			boardMetrics.closedIssueCounter, boardMetrics.incompleteIssueCounter)
+	}
+	if boardMetrics.averageCycleTime != 6 {
+		t.Errorf("Expected an average cycle time of 6 days, but got %v", boardMetrics.averageCycleTime)
+	}
+	if len(boardMetrics.agingIssues) != 0 {
+		t.Errorf("Expected no aging issues, but got %v", boardMetrics.agingIssues)
+	}
+
+	// Issues in an end column don't age anymore
+	config.Boards["test"] = board{EndColumns: []string{"Deployed"}}
+	open.Columns = []boardColumn{{Board: "test", Column: "Deployed"}}
+	boardMetrics = NewMetrics(&WorkItems{Repository: "brejoc/test", Items: []workItem{open}}).Board["test"]
+	if len(boardMetrics.agingIssues) != 0 {
+		t.Errorf("Expected no aging issues in the end column, but got %v", boardMetrics.agingIssues)
+	}
+}
+
+func TestNewMetricsRemovedIssues(t *testing.T) {
+	// loading test config
+	loadConfig("./test-data/test_config.toml")
+
+	removed := workItem{
+		Url:       "https://github.com/brejoc/test/issues/1",
+		State:     stateOpen,
+		CreatedAt: day(1),
+		Labels:    []string{},
+		Columns:   []boardColumn{},
+		Log: []itemEvent{
+			{Type: eventAdded, CreatedAt: day(1), Board: "test"},
+			{Type: eventMoved, CreatedAt: day(3), Board: "test", PreviousColumn: "To do", Column: "In progress"},
+			{Type: eventRemoved, CreatedAt: day(5), Board: "test"},
+		},
+	}
+	// Removed and added again to another column
+	readded := removed
+	readded.Url = "https://github.com/brejoc/test/issues/2"
+	readded.Columns = []boardColumn{{Board: "test", Column: "Planned"}}
+	readded.Log = append(removed.Log[:3:3], itemEvent{Type: eventAdded, CreatedAt: day(7), Board: "test"})
+
+	got := NewMetrics(&WorkItems{Repository: "brejoc/test", Items: []workItem{removed, readded}}).Board["test"]
+	want := map[string]map[string]int{
+		"2019-06-01": {"To do": 2},
+		"2019-06-03": {"To do": -2, "In progress": 2},
+		"2019-06-05": {"In progress": -2},
+		"2019-06-07": {"Planned": 1},
+	}
+	if !reflect.DeepEqual(got.cumulativeFlow.changes, want) {
+		t.Errorf("Expected the cumulative flow %v, but got %v", want, got.cumulativeFlow.changes)
+	}
+	if got.openIssueCounter != 1 {
+		t.Errorf("Expected only the issue on the board to be counted, but got %d", got.openIssueCounter)
+	}
+}
@@ -1,12 +1,14 @@
-// Currently this is only needed for testing
-
+// Package persist stores and loads values as JSON files. It is used for the
+// issue cache and for the test data.
 package persist
 
 import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sync"
 )
 
@@ -30,21 +32,33 @@ var Unmarshal = func(r io.Reader, v interface{}) error {
 	return json.NewDecoder(r).Decode(v)
 }
 
-// Save saves a representation of v to the file at path.
+// Save saves a representation of v to the file at path. The representation
+// is written to a temporary file first, which then replaces the file at path,
+// so that the file is never left partly written.
 func Save(path string, v interface{}) error {
 	lock.Lock()
 	defer lock.Unlock()
-	f, err := os.Create(path)
+	r, err := Marshal(v)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	r, err := Marshal(v)
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
 	if err != nil {
 		return err
 	}
-	_, err = io.Copy(f, r)
-	return err
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
 }
 
 // Load loads the file at path into v.
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// restClient is the HTTP client of the sources with REST APIs.
+var restClient = &http.Client{Timeout: time.Minute}
+
+// statusError is the error of a response with a status other than 200 OK.
+type statusError struct {
+	StatusCode int
+	Status     string
+	Body       []byte
+}
+
+func (err *statusError) Error() string {
+	return fmt.Sprintf("non-200 OK status code: %v body: %q", err.Status, err.Body)
+}
+
+// getJSON requests the given address with the given headers and decodes the JSON response into v. Requests failing
+// with a transient error are retried. The headers of the response are returned, e.g. for pagination.
+func getJSON(address string, header http.Header, v interface{}) (http.Header, error) {
+	var respHeader http.Header
+	err := retry(func() error {
+		req, err := http.NewRequest(http.MethodGet, address, nil)
+		if err != nil {
+			return err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := restClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			body, _ := ioutil.ReadAll(resp.Body)
+			return &statusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+		}
+		respHeader = resp.Header
+		return json.NewDecoder(resp.Body).Decode(v)
+	})
+	return respHeader, err
+}
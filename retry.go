@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brejoc/githubv4"
+	log "github.com/sirupsen/logrus"
+)
+
+// statusCodePattern matches the status code in the errors of unsuccessful responses.
+var statusCodePattern = regexp.MustCompile(`(?i)non-200 OK status code: (\d{3})`)
+
+// isTransientError checks if a query failed because of an error that might go away when the query is retried. These
+// are timeouts, server errors and the secondary rate limits of Github.
+func isTransientError(err error) bool {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	if err == context.DeadlineExceeded {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	if match := statusCodePattern.FindStringSubmatch(message); match != nil {
+		code, _ := strconv.Atoi(match[1])
+		switch {
+		case code >= 500, code == 429:
+			return true
+		case code == 403:
+			return strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse")
+		}
+		return false
+	}
+	return strings.Contains(message, "timeout") || strings.Contains(message, "connection reset")
+}
+
+// maxRetryDelay is the longest delay before a retry, no matter how many retries are configured.
+const maxRetryDelay = time.Hour
+
+// retryDelay returns how long to wait before the given retry. The delay doubles with every retry up to an hour and is
+// randomized between half and the full delay, so that multiple clients do not retry at the same time.
+func retryDelay(retry int, rng *rand.Rand) time.Duration {
+	delay := config.Fetch.retryBackoff()
+	for i := 1; i < retry && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay/2 + time.Duration(rng.Int63n(int64(delay/2)+1))
+}
+
+// retry runs the given function and runs it again with exponential backoff when it fails with a transient error.
+func retry(run func() error) error {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for retry := 1; ; retry++ {
+		err := run()
+		if err == nil || !isTransientError(err) || retry > config.Fetch.maxRetries() {
+			return err
+		}
+		delay := retryDelay(retry, rng)
+		log.Warnf("Request failed with %s, retrying in %s (%d/%d)", err, delay, retry, config.Fetch.maxRetries())
+		sleep(delay)
+	}
+}
+
+// queryWithRetries runs a query and retries it with exponential backoff when it fails with a transient error.
+func queryWithRetries(client *githubv4.Client, query interface{}, variables map[string]interface{}) error {
+	return retry(func() error {
+		// Drop whatever a failed attempt decoded
+		value := reflect.ValueOf(query).Elem()
+		value.Set(reflect.Zero(value.Type()))
+		return client.Query(context.Background(), query, variables)
+	})
+}
@@ -0,0 +1,66 @@
+package main
+
+import "time"
+
+// dayLayout is the layout of the dates the throughput is bucketed by.
+const dayLayout = "2006-01-02"
+
+// Throughput stores the number of issues closed per day and per ISO week.
+// Days are keyed by their date and weeks by the date of their Monday.
+type Throughput struct {
+	days  map[string]int
+	weeks map[string]int
+}
+
+// newThroughput returns an empty Throughput.
+func newThroughput() Throughput {
+	return Throughput{days: map[string]int{}, weeks: map[string]int{}}
+}
+
+// add counts an issue closed at closedAt.
+func (throughput Throughput) add(closedAt time.Time) {
+	throughput.days[closedAt.UTC().Format(dayLayout)]++
+	throughput.weeks[startOfWeek(closedAt).Format(dayLayout)]++
+}
+
+// daySeries returns the throughput of every day from the first day an issue
+// was closed until the given time, including the days without closed issues.
+func (throughput Throughput) daySeries(until time.Time) map[string]interface{} {
+	return fillSeries(throughput.days, until.UTC(), 1)
+}
+
+// weekSeries returns the throughput of every week from the first week an issue
+// was closed until the given time, including the weeks without closed issues.
+func (throughput Throughput) weekSeries(until time.Time) map[string]interface{} {
+	return fillSeries(throughput.weeks, startOfWeek(until), 7)
+}
+
+// fillSeries returns the counts of all buckets from the first bucket until the
+// given time. Buckets are the given number of days apart.
+func fillSeries(counts map[string]int, until time.Time, days int) map[string]interface{} {
+	series := map[string]interface{}{}
+	if len(counts) == 0 {
+		return series
+	}
+
+	first := until
+	for bucket := range counts {
+		start, err := time.Parse(dayLayout, bucket)
+		if err == nil && start.Before(first) {
+			first = start
+		}
+	}
+	for bucket := first; !bucket.After(until); bucket = bucket.AddDate(0, 0, days) {
+		series[bucket.Format(dayLayout)] = counts[bucket.Format(dayLayout)]
+	}
+	return series
+}
+
+// startOfWeek returns the Monday of the ISO week of the given time in UTC.
+func startOfWeek(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	// ISO weeks start on Monday, time.Weekday on Sunday
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
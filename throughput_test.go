@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestThroughputSeries(t *testing.T) {
+	throughput := newThroughput()
+	// Saturday and Sunday of one week and Monday of the next week
+	throughput.add(time.Date(2019, 6, 29, 10, 0, 0, 0, time.UTC))
+	throughput.add(time.Date(2019, 6, 30, 23, 0, 0, 0, time.UTC))
+	throughput.add(time.Date(2019, 7, 1, 8, 0, 0, 0, time.UTC))
+	throughput.add(time.Date(2019, 7, 1, 9, 0, 0, 0, time.UTC))
+
+	until := time.Date(2019, 7, 9, 12, 0, 0, 0, time.UTC)
+
+	wantDays := map[string]interface{}{
+		"2019-06-29": 1, "2019-06-30": 1, "2019-07-01": 2, "2019-07-02": 0, "2019-07-03": 0,
+		"2019-07-04": 0, "2019-07-05": 0, "2019-07-06": 0, "2019-07-07": 0, "2019-07-08": 0, "2019-07-09": 0,
+	}
+	if got := throughput.daySeries(until); !reflect.DeepEqual(got, wantDays) {
+		t.Errorf("Got %v as daily throughput, but expected %v", got, wantDays)
+	}
+
+	wantWeeks := map[string]interface{}{"2019-06-24": 2, "2019-07-01": 2, "2019-07-08": 0}
+	if got := throughput.weekSeries(until); !reflect.DeepEqual(got, wantWeeks) {
+		t.Errorf("Got %v as weekly throughput, but expected %v", got, wantWeeks)
+	}
+}
+
+func TestStartOfWeek(t *testing.T) {
+	want := time.Date(2019, 12, 30, 0, 0, 0, 0, time.UTC)
+	for _, day := range []int{30, 31, 32, 33, 34, 35} {
+		got := startOfWeek(time.Date(2019, 12, day, 15, 0, 0, 0, time.UTC))
+		if !got.Equal(want) {
+			t.Errorf("Got %s as start of the week of %d, but expected %s", got, day, want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The states of work items
+const (
+	stateOpen   = "OPEN"
+	stateClosed = "CLOSED"
+)
+
+// WorkItems holds the work items of a single repository, independent of the
+// source they were fetched from.
+type WorkItems struct {
+	Repository string
+	Items      []workItem
+}
+
+// workItem is an issue with its history on the configured boards.
+type workItem struct {
+	Url       string
+	Title     string
+	State     string
+	CreatedAt time.Time
+	// ClosedAt is zero for open items
+	ClosedAt time.Time
+	// StateReason is why the item was closed, e.g. "COMPLETED", "NOT_PLANNED"
+	// or "DUPLICATE" on Github and the resolution on Jira. It is empty if the
+	// source does not tell.
+	StateReason string
+	Labels      []string
+	// Columns holds the configured boards the item is currently on with its column
+	Columns []boardColumn
+	// Log holds the events of the item in the order they happened
+	Log []itemEvent
+}
+
+// The types of the events of work items
+const (
+	// eventAdded, eventMoved and eventRemoved are events on a configured board
+	eventAdded   = "ADDED"
+	eventMoved   = "MOVED"
+	eventRemoved = "REMOVED"
+	// eventConverted is a note on a configured board being converted into the item
+	eventConverted = "CONVERTED"
+	eventLabeled   = "LABELED"
+	eventUnlabeled = "UNLABELED"
+	eventClosed    = "CLOSED"
+	eventReopened  = "REOPENED"
+	eventAssigned  = "ASSIGNED"
+)
+
+// itemEvent is an event in the lifecycle of a work item.
+type itemEvent struct {
+	Type      string
+	CreatedAt time.Time
+	// Board is the configured board of events on boards
+	Board          string
+	PreviousColumn string
+	Column         string
+	// Label is the label of labeled and unlabeled events
+	Label string
+	// Assignee is the login of the assignee of assigned events
+	Assignee string
+}
+
+// addBoardEvents adds the column events of the item on a configured board to the log.
+func (item *workItem) addBoardEvents(boardName string, events []columnEvent) {
+	for _, event := range events {
+		logEvent := itemEvent{Type: eventMoved, CreatedAt: event.CreatedAt, Board: boardName,
+			PreviousColumn: event.PreviousColumn, Column: event.Column}
+		if event.Added {
+			logEvent.Type = eventAdded
+		}
+		item.Log = append(item.Log, logEvent)
+	}
+}
+
+// sortLog orders the log by when the events happened. Events that happened at
+// the same time keep their order.
+func (item *workItem) sortLog() {
+	sort.SliceStable(item.Log, func(i, j int) bool {
+		return item.Log[i].CreatedAt.Before(item.Log[j].CreatedAt)
+	})
+}
+
+// events returns the events of the given types in the order they happened.
+func (item workItem) events(types ...string) []itemEvent {
+	events := []itemEvent{}
+	for _, event := range item.Log {
+		for _, eventType := range types {
+			if event.Type == eventType {
+				events = append(events, event)
+				break
+			}
+		}
+	}
+	return events
+}
+
+// boardEvents returns the events of the item being added to the given board
+// or moved between its columns in the order they happened.
+func (item workItem) boardEvents(boardName string) []columnEvent {
+	events := []columnEvent{}
+	for _, event := range item.events(eventAdded, eventConverted, eventMoved) {
+		if event.Board == boardName {
+			events = append(events, columnEvent{
+				Added:          event.Type != eventMoved,
+				PreviousColumn: event.PreviousColumn,
+				Column:         event.Column,
+				CreatedAt:      event.CreatedAt,
+			})
+		}
+	}
+	return events
+}
+
+// activeInterval is a period in which a work item was open. End is zero while the item is still open.
+type activeInterval struct {
+	Start time.Time
+	End   time.Time
+}
+
+// isOnBoard reports whether the item is currently on the given board.
+func (item workItem) isOnBoard(boardName string) bool {
+	for _, column := range item.Columns {
+		if column.Board == boardName {
+			return true
+		}
+	}
+	return false
+}
+
+// removals returns when the item was removed from the given board in the order it happened.
+func (item workItem) removals(boardName string) []time.Time {
+	removals := []time.Time{}
+	for _, event := range item.events(eventRemoved) {
+		if event.Board == boardName {
+			removals = append(removals, event.CreatedAt)
+		}
+	}
+	return removals
+}
+
+// activeIntervals returns the periods the item was open in, from its creation
+// until it was closed and from every reopening until the following close.
+// Items without closed events in their log were open until ClosedAt.
+func (item workItem) activeIntervals() []activeInterval {
+	intervals := []activeInterval{}
+	start := item.CreatedAt
+	open := true
+	for _, event := range item.events(eventClosed, eventReopened) {
+		if event.Type == eventClosed && open {
+			intervals = append(intervals, activeInterval{Start: start, End: event.CreatedAt})
+			open = false
+		} else if event.Type == eventReopened && !open {
+			start = event.CreatedAt
+			open = true
+		}
+	}
+	if open {
+		intervals = append(intervals, activeInterval{Start: start, End: item.ClosedAt})
+	}
+	return intervals
+}
+
+// firstClosedAt returns when the item was closed for the first time. Items
+// without closed events in their log were closed at ClosedAt.
+func (item workItem) firstClosedAt() time.Time {
+	if closed := item.events(eventClosed); len(closed) > 0 {
+		return closed[0].CreatedAt
+	}
+	return item.ClosedAt
+}
+
+// wasReopened reports whether the item was reopened after it was closed.
+func (item workItem) wasReopened() bool {
+	return len(item.events(eventReopened)) > 0
+}
+
+// boardColumn is the column an issue is currently in on a board.
+type boardColumn struct {
+	Board  string
+	Column string
+}
+
+// columnEvent is an issue being added to a board or moved between two of
+// its columns.
+type columnEvent struct {
+	Added          bool
+	PreviousColumn string
+	Column         string
+	CreatedAt      time.Time
+}
+
+// issueSource fetches the work items of repositories from an issue tracker.
+type issueSource interface {
+	fetch(repos []repository) ([]*WorkItems, error)
+}
+
+// sources holds the issue sources by the source type of the repositories.
+var sources = map[string]issueSource{
+	"github":  githubSource{},
+	"gitlab":  gitlabSource{},
+	"jira":    jiraSource{},
+	"gitea":   giteaSource{},
+	"forgejo": giteaSource{},
+}
+
+// FetchAllWorkItems fetches the work items of all configured repositories
+// from their sources.
+func FetchAllWorkItems() ([]*WorkItems, error) {
+	reposBySource := map[string][]repository{}
+	order := []string{}
+	for _, repo := range config.Repositories {
+		if _, ok := sources[repo.source()]; !ok {
+			return nil, fmt.Errorf("unknown source %q of repository %s", repo.Source, repo.fullName())
+		}
+		if _, ok := reposBySource[repo.source()]; !ok {
+			order = append(order, repo.source())
+		}
+		reposBySource[repo.source()] = append(reposBySource[repo.source()], repo)
+	}
+
+	results := []*WorkItems{}
+	for _, sourceType := range order {
+		workItems, err := sources[sourceType].fetch(reposBySource[sourceType])
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, workItems...)
+	}
+	return results, nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestActiveIntervals(t *testing.T) {
+	tests := []struct {
+		item      workItem
+		want      []activeInterval
+		firstDone time.Time
+		reopened  bool
+	}{
+		// Closed without events in the log
+		{
+			workItem{State: stateClosed, CreatedAt: day(1), ClosedAt: day(5), Log: []itemEvent{}},
+			[]activeInterval{{Start: day(1), End: day(5)}},
+			day(5),
+			false,
+		},
+		// Closed, reopened and closed again
+		{
+			workItem{State: stateClosed, CreatedAt: day(1), ClosedAt: day(20), Log: []itemEvent{
+				{Type: eventClosed, CreatedAt: day(3)},
+				{Type: eventLabeled, CreatedAt: day(4), Label: "bug"},
+				{Type: eventReopened, CreatedAt: day(10)},
+				{Type: eventClosed, CreatedAt: day(20)},
+			}},
+			[]activeInterval{{Start: day(1), End: day(3)}, {Start: day(10), End: day(20)}},
+			day(3),
+			true,
+		},
+		// Reopened and still open
+		{
+			workItem{State: stateOpen, CreatedAt: day(1), Log: []itemEvent{
+				{Type: eventClosed, CreatedAt: day(3)},
+				{Type: eventReopened, CreatedAt: day(10)},
+			}},
+			[]activeInterval{{Start: day(1), End: day(3)}, {Start: day(10)}},
+			day(3),
+			true,
+		},
+	}
+	for _, test := range tests {
+		if got := test.item.activeIntervals(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Got %v as active intervals, but expected %v", got, test.want)
+		}
+		if got := test.item.firstClosedAt(); !got.Equal(test.firstDone) {
+			t.Errorf("Got %s as first close, but expected %s", got, test.firstDone)
+		}
+		if got := test.item.wasReopened(); got != test.reopened {
+			t.Errorf("Got %v for reopened, but expected %v", got, test.reopened)
+		}
+	}
+}